@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessServiceTokenSpec defines the desired state of AccessServiceToken
+type AccessServiceTokenSpec struct {
+	// RemoteName is the name of the service token in the remote, defaults to `<namespace>-<name>`
+	// +kubebuilder:validation:Optional
+	RemoteName string `json:"remoteName,omitempty"`
+	// SecretName is the Secret the client id and client secret are written to, defaults to `<name>-service-token`
+	// +kubebuilder:validation:Optional
+	SecretName      string `json:"secretName,omitempty"`
+	TokenSecretName string `json:"tokenSecretName"`
+	// RenewBefore is how long before the expiry the token is rotated
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="720h"
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+	// Overlap is how long the previous token stays valid after a rotation
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="24h"
+	Overlap *metav1.Duration `json:"overlap,omitempty"`
+}
+
+// AccessServiceTokenStatus defines the observed state of AccessServiceToken
+type AccessServiceTokenStatus struct {
+	// +kubebuilder:validation:Format="uuid"
+	TokenID   string       `json:"tokenID,omitempty"`
+	ClientID  string       `json:"clientID,omitempty"`
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// PreviousTokenID is the token replaced by the last rotation, deleted at PreviousTokenDeleteAt
+	// +kubebuilder:validation:Format="uuid"
+	PreviousTokenID       string       `json:"previousTokenID,omitempty"`
+	PreviousTokenDeleteAt *metav1.Time `json:"previousTokenDeleteAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Client ID",type=string,JSONPath=`.status.clientID`
+//+kubebuilder:printcolumn:name="Expires At",type=string,format=date-time,JSONPath=`.status.expiresAt`
+
+// AccessServiceToken is the Schema for the accessservicetokens API
+type AccessServiceToken struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessServiceTokenSpec   `json:"spec,omitempty"`
+	Status AccessServiceTokenStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AccessServiceTokenList contains a list of AccessServiceToken
+type AccessServiceTokenList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessServiceToken `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessServiceToken{}, &AccessServiceTokenList{})
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+)
+
+// ConvertedFieldsAnnotation keeps the fields of a v1beta1 resource that v1alpha1 has no place for, so that they survive
+// a round trip through v1alpha1
+const ConvertedFieldsAnnotation = "cloudflare-tunnel-operator.beezlabs.app/v1beta1-fields"
+
+type convertedFields struct {
+	// Ingress are the rules between the first rule, which is domain and service, and the catch-all rule
+	Ingress []v1beta1.CloudflareTunnelIngressRule `json:"ingress,omitempty"`
+	// AccountSecretRef is only kept when it uses other keys than the fixed ones of v1alpha1
+	AccountSecretRef *v1beta1.CloudflareTunnelAccountSecretRef `json:"accountSecretRef,omitempty"`
+	TunnelRef        *v1beta1.CloudflareTunnelRef              `json:"tunnelRef,omitempty"`
+}
+
+var _ conversion.Convertible = &CloudflareTunnel{}
+
+// ConvertTo converts this CloudflareTunnel to the Hub version (v1beta1).
+func (src *CloudflareTunnel) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.CloudflareTunnel)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	var fields convertedFields
+	if data, ok := dst.Annotations[ConvertedFieldsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			return err
+		}
+		delete(dst.Annotations, ConvertedFieldsAnnotation)
+	}
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = v1beta1.CloudflareTunnelSpec{
+		Zone:      spec.Zone,
+		DNS:       (*v1beta1.CloudflareTunnelDNS)(spec.DNS),
+		Container: (*v1beta1.CloudflareTunnelContainer)(spec.Container),
+		AccountSecretRef: v1beta1.CloudflareTunnelAccountSecretRef{
+			Name:         spec.TokenSecretName,
+			TokenKey:     TokenSecretTokenKey,
+			AccountIDKey: TokenSecretAccountIDKey,
+		},
+		Replicas:         spec.Replicas,
+		WorkloadKind:     spec.WorkloadKind,
+		Autoscaling:      (*v1beta1.CloudflareTunnelAutoscaling)(spec.Autoscaling),
+		PodTemplate:      (*v1beta1.CloudflareTunnelPodTemplate)(spec.PodTemplate),
+		HighAvailability: (*v1beta1.CloudflareTunnelHighAvailability)(spec.HighAvailability),
+		Monitoring:       convertMonitoringTo(spec.Monitoring),
+		Probes:           convertProbesTo(spec.Probes),
+		CredentialsType:  spec.CredentialsType,
+		OriginRequest:    convertOriginRequestTo(spec.OriginRequest),
+		OriginCA:         (*v1beta1.CloudflareTunnelOriginCA)(spec.OriginCA),
+		PrivateNetwork:   (*v1beta1.CloudflareTunnelPrivateNetwork)(spec.PrivateNetwork),
+		Access:           convertAccessTo(spec.Access),
+		TunnelRef:        fields.TunnelRef,
+	}
+	if fields.AccountSecretRef != nil {
+		dst.Spec.AccountSecretRef.TokenKey = fields.AccountSecretRef.TokenKey
+		dst.Spec.AccountSecretRef.AccountIDKey = fields.AccountSecretRef.AccountIDKey
+	}
+
+	// the service of v1alpha1 only matches domain when there is a catch-all service, without one it matches everything
+	// routed to the tunnel, which in practice is domain as that is the only record pointing to the tunnel
+	if spec.Service != nil {
+		dst.Spec.Ingress = append(dst.Spec.Ingress, v1beta1.CloudflareTunnelIngressRule{
+			Hostname: spec.Domain,
+			Service:  convertServiceTo(spec.Service),
+		})
+	}
+	dst.Spec.Ingress = append(dst.Spec.Ingress, fields.Ingress...)
+	if spec.CatchAll != nil {
+		dst.Spec.Ingress = append(dst.Spec.Ingress, v1beta1.CloudflareTunnelIngressRule{
+			Service: convertServiceTo(spec.CatchAll),
+		})
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = v1beta1.CloudflareTunnelStatus{
+		TunnelID:             status.TunnelID,
+		RemoteName:           status.RemoteName,
+		Adopted:              status.Adopted,
+		AccessApplicationID:  status.AccessApplicationID,
+		AccessApplicationAUD: status.AccessApplicationAUD,
+		Conditions:           status.Conditions,
+	}
+	for _, connection := range status.Connections {
+		dst.Status.Connections = append(dst.Status.Connections, v1beta1.CloudflareTunnelConnections(connection))
+	}
+	for _, route := range status.Routes {
+		dst.Status.Routes = append(dst.Status.Routes, v1beta1.CloudflareTunnelRoute(route))
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *CloudflareTunnel) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.CloudflareTunnel)
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+
+	spec := src.Spec.DeepCopy()
+	dst.Spec = CloudflareTunnelSpec{
+		Zone:             spec.Zone,
+		DNS:              (*CloudflareTunnelDNS)(spec.DNS),
+		Container:        (*CloudflareTunnelContainer)(spec.Container),
+		TokenSecretName:  spec.AccountSecretRef.Name,
+		Replicas:         spec.Replicas,
+		WorkloadKind:     spec.WorkloadKind,
+		Autoscaling:      (*CloudflareTunnelAutoscaling)(spec.Autoscaling),
+		PodTemplate:      (*CloudflareTunnelPodTemplate)(spec.PodTemplate),
+		HighAvailability: (*CloudflareTunnelHighAvailability)(spec.HighAvailability),
+		Monitoring:       convertMonitoringFrom(spec.Monitoring),
+		Probes:           convertProbesFrom(spec.Probes),
+		CredentialsType:  spec.CredentialsType,
+		OriginRequest:    convertOriginRequestFrom(spec.OriginRequest),
+		OriginCA:         (*CloudflareTunnelOriginCA)(spec.OriginCA),
+		PrivateNetwork:   (*CloudflareTunnelPrivateNetwork)(spec.PrivateNetwork),
+		Access:           convertAccessFrom(spec.Access),
+	}
+
+	var fields convertedFields
+	ingress := spec.Ingress
+	if len(ingress) != 0 {
+		dst.Spec.Domain = ingress[0].Hostname
+		dst.Spec.Service = convertServiceFrom(&ingress[0].Service)
+		ingress = ingress[1:]
+	}
+	if last := len(ingress) - 1; last >= 0 && ingress[last].Hostname == "" {
+		dst.Spec.CatchAll = convertServiceFrom(&ingress[last].Service)
+		ingress = ingress[:last]
+	}
+	fields.Ingress = ingress
+	if ref := spec.AccountSecretRef; ref.TokenKey != TokenSecretTokenKey || ref.AccountIDKey != TokenSecretAccountIDKey {
+		fields.AccountSecretRef = &ref
+	}
+	fields.TunnelRef = spec.TunnelRef
+	if len(fields.Ingress) != 0 || fields.AccountSecretRef != nil || fields.TunnelRef != nil {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[ConvertedFieldsAnnotation] = string(data)
+	}
+
+	status := src.Status.DeepCopy()
+	dst.Status = CloudflareTunnelStatus{
+		TunnelID:             status.TunnelID,
+		RemoteName:           status.RemoteName,
+		Adopted:              status.Adopted,
+		AccessApplicationID:  status.AccessApplicationID,
+		AccessApplicationAUD: status.AccessApplicationAUD,
+		Conditions:           status.Conditions,
+	}
+	for _, connection := range status.Connections {
+		dst.Status.Connections = append(dst.Status.Connections, CloudflareTunnelConnections(connection))
+	}
+	for _, route := range status.Routes {
+		dst.Status.Routes = append(dst.Status.Routes, CloudflareTunnelRoute(route))
+	}
+	return nil
+}
+
+// the types below differ between the versions only in the types of their fields, the ones with identical fields are
+// converted directly
+
+func convertServiceTo(in *CloudflareTunnelService) v1beta1.CloudflareTunnelService {
+	return v1beta1.CloudflareTunnelService{
+		Name:          in.Name,
+		Namespace:     in.Namespace,
+		Host:          in.Host,
+		Protocol:      in.Protocol,
+		Port:          in.Port,
+		Path:          in.Path,
+		SocketVolume:  (*v1beta1.CloudflareTunnelSocketVolume)(in.SocketVolume),
+		StatusCode:    in.StatusCode,
+		OriginRequest: convertOriginRequestTo(in.OriginRequest),
+	}
+}
+
+func convertServiceFrom(in *v1beta1.CloudflareTunnelService) *CloudflareTunnelService {
+	return &CloudflareTunnelService{
+		Name:          in.Name,
+		Namespace:     in.Namespace,
+		Host:          in.Host,
+		Protocol:      in.Protocol,
+		Port:          in.Port,
+		Path:          in.Path,
+		SocketVolume:  (*CloudflareTunnelSocketVolume)(in.SocketVolume),
+		StatusCode:    in.StatusCode,
+		OriginRequest: convertOriginRequestFrom(in.OriginRequest),
+	}
+}
+
+func convertOriginRequestTo(in *CloudflareTunnelOriginRequest) *v1beta1.CloudflareTunnelOriginRequest {
+	if in == nil {
+		return nil
+	}
+	out := &v1beta1.CloudflareTunnelOriginRequest{
+		ConnectTimeout:         in.ConnectTimeout,
+		TLSTimeout:             in.TLSTimeout,
+		TCPKeepAlive:           in.TCPKeepAlive,
+		NoHappyEyeballs:        in.NoHappyEyeballs,
+		KeepAliveConnections:   in.KeepAliveConnections,
+		KeepAliveTimeout:       in.KeepAliveTimeout,
+		HTTPHostHeader:         in.HTTPHostHeader,
+		OriginServerName:       in.OriginServerName,
+		CAPool:                 in.CAPool,
+		NoTLSVerify:            in.NoTLSVerify,
+		DisableChunkedEncoding: in.DisableChunkedEncoding,
+		BastionMode:            in.BastionMode,
+		ProxyAddress:           in.ProxyAddress,
+		ProxyPort:              in.ProxyPort,
+		ProxyType:              in.ProxyType,
+		HTTP2Origin:            in.HTTP2Origin,
+		Access:                 (*v1beta1.CloudflareTunnelOriginAccess)(in.Access),
+	}
+	for _, ipRule := range in.IPRules {
+		out.IPRules = append(out.IPRules, v1beta1.CloudflareTunnelIPRule(ipRule))
+	}
+	return out
+}
+
+func convertOriginRequestFrom(in *v1beta1.CloudflareTunnelOriginRequest) *CloudflareTunnelOriginRequest {
+	if in == nil {
+		return nil
+	}
+	out := &CloudflareTunnelOriginRequest{
+		ConnectTimeout:         in.ConnectTimeout,
+		TLSTimeout:             in.TLSTimeout,
+		TCPKeepAlive:           in.TCPKeepAlive,
+		NoHappyEyeballs:        in.NoHappyEyeballs,
+		KeepAliveConnections:   in.KeepAliveConnections,
+		KeepAliveTimeout:       in.KeepAliveTimeout,
+		HTTPHostHeader:         in.HTTPHostHeader,
+		OriginServerName:       in.OriginServerName,
+		CAPool:                 in.CAPool,
+		NoTLSVerify:            in.NoTLSVerify,
+		DisableChunkedEncoding: in.DisableChunkedEncoding,
+		BastionMode:            in.BastionMode,
+		ProxyAddress:           in.ProxyAddress,
+		ProxyPort:              in.ProxyPort,
+		ProxyType:              in.ProxyType,
+		HTTP2Origin:            in.HTTP2Origin,
+		Access:                 (*CloudflareTunnelOriginAccess)(in.Access),
+	}
+	for _, ipRule := range in.IPRules {
+		out.IPRules = append(out.IPRules, CloudflareTunnelIPRule(ipRule))
+	}
+	return out
+}
+
+func convertAccessTo(in *CloudflareTunnelAccess) *v1beta1.CloudflareTunnelAccess {
+	if in == nil {
+		return nil
+	}
+	out := &v1beta1.CloudflareTunnelAccess{
+		Name:            in.Name,
+		SessionDuration: in.SessionDuration,
+		TeamName:        in.TeamName,
+	}
+	for _, policy := range in.Policies {
+		out.Policies = append(out.Policies, v1beta1.CloudflareTunnelAccessPolicy{
+			Name:     policy.Name,
+			Decision: policy.Decision,
+			Include:  (*v1beta1.CloudflareTunnelAccessRules)(policy.Include),
+			Exclude:  (*v1beta1.CloudflareTunnelAccessRules)(policy.Exclude),
+			Require:  (*v1beta1.CloudflareTunnelAccessRules)(policy.Require),
+		})
+	}
+	return out
+}
+
+func convertAccessFrom(in *v1beta1.CloudflareTunnelAccess) *CloudflareTunnelAccess {
+	if in == nil {
+		return nil
+	}
+	out := &CloudflareTunnelAccess{
+		Name:            in.Name,
+		SessionDuration: in.SessionDuration,
+		TeamName:        in.TeamName,
+	}
+	for _, policy := range in.Policies {
+		out.Policies = append(out.Policies, CloudflareTunnelAccessPolicy{
+			Name:     policy.Name,
+			Decision: policy.Decision,
+			Include:  (*CloudflareTunnelAccessRules)(policy.Include),
+			Exclude:  (*CloudflareTunnelAccessRules)(policy.Exclude),
+			Require:  (*CloudflareTunnelAccessRules)(policy.Require),
+		})
+	}
+	return out
+}
+
+func convertMonitoringTo(in *CloudflareTunnelMonitoring) *v1beta1.CloudflareTunnelMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := &v1beta1.CloudflareTunnelMonitoring{
+		Kind:     in.Kind,
+		Interval: in.Interval,
+		Labels:   in.Labels,
+	}
+	for _, relabeling := range in.Relabelings {
+		out.Relabelings = append(out.Relabelings, v1beta1.CloudflareTunnelRelabelConfig(relabeling))
+	}
+	return out
+}
+
+func convertMonitoringFrom(in *v1beta1.CloudflareTunnelMonitoring) *CloudflareTunnelMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := &CloudflareTunnelMonitoring{
+		Kind:     in.Kind,
+		Interval: in.Interval,
+		Labels:   in.Labels,
+	}
+	for _, relabeling := range in.Relabelings {
+		out.Relabelings = append(out.Relabelings, CloudflareTunnelRelabelConfig(relabeling))
+	}
+	return out
+}
+
+func convertProbesTo(in *CloudflareTunnelProbes) *v1beta1.CloudflareTunnelProbes {
+	if in == nil {
+		return nil
+	}
+	return &v1beta1.CloudflareTunnelProbes{
+		Readiness: (*v1beta1.CloudflareTunnelProbe)(in.Readiness),
+		Liveness:  (*v1beta1.CloudflareTunnelProbe)(in.Liveness),
+	}
+}
+
+func convertProbesFrom(in *v1beta1.CloudflareTunnelProbes) *CloudflareTunnelProbes {
+	if in == nil {
+		return nil
+	}
+	return &CloudflareTunnelProbes{
+		Readiness: (*CloudflareTunnelProbe)(in.Readiness),
+		Liveness:  (*CloudflareTunnelProbe)(in.Liveness),
+	}
+}
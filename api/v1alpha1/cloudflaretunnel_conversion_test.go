@@ -0,0 +1,103 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"math/rand"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+)
+
+const fuzzIterations = 1000
+
+func newFuzzer(t *testing.T) *fuzz.Fuzzer {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	funcs := fuzzer.MergeFuzzerFuncs(metafuzzer.Funcs, cloudflareTunnelFuzzerFuncs)
+	return fuzzer.FuzzerFor(funcs, rand.NewSource(rand.Int63()), serializer.NewCodecFactory(scheme)).NilChance(0.2)
+}
+
+func cloudflareTunnelFuzzerFuncs(_ serializer.CodecFactory) []interface{} {
+	return []interface{}{
+		// the type meta is set by the conversion webhook and not by the conversion itself
+		func(in *metav1.TypeMeta, c fuzz.Continue) {},
+		// service is required in v1alpha1, v1beta1 has no place for the domain of a tunnel without a service
+		func(in *CloudflareTunnelSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(in)
+			if in.Service == nil {
+				in.Service = &CloudflareTunnelService{}
+			}
+		},
+	}
+}
+
+func TestCloudflareTunnelConversionSpokeHubSpoke(t *testing.T) {
+	f := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		original := &CloudflareTunnel{}
+		f.Fuzz(original)
+
+		hub := &v1beta1.CloudflareTunnel{}
+		if err := original.DeepCopy().ConvertTo(hub); err != nil {
+			t.Fatalf("could not convert to v1beta1: %v", err)
+		}
+		converted := &CloudflareTunnel{}
+		if err := converted.ConvertFrom(hub); err != nil {
+			t.Fatalf("could not convert from v1beta1: %v", err)
+		}
+
+		if !apiequality.Semantic.DeepEqual(original, converted) {
+			t.Fatalf("v1alpha1 changed in a round trip through v1beta1:\n%s", diff.ObjectReflectDiff(original, converted))
+		}
+	}
+}
+
+func TestCloudflareTunnelConversionHubSpokeHub(t *testing.T) {
+	f := newFuzzer(t)
+	for i := 0; i < fuzzIterations; i++ {
+		original := &v1beta1.CloudflareTunnel{}
+		f.Fuzz(original)
+
+		spoke := &CloudflareTunnel{}
+		if err := spoke.ConvertFrom(original.DeepCopy()); err != nil {
+			t.Fatalf("could not convert from v1beta1: %v", err)
+		}
+		converted := &v1beta1.CloudflareTunnel{}
+		if err := spoke.ConvertTo(converted); err != nil {
+			t.Fatalf("could not convert to v1beta1: %v", err)
+		}
+
+		if !apiequality.Semantic.DeepEqual(original, converted) {
+			t.Fatalf("v1beta1 changed in a round trip through v1alpha1:\n%s", diff.ObjectReflectDiff(original, converted))
+		}
+	}
+}
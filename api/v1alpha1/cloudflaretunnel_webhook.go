@@ -0,0 +1,28 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func (r *CloudflareTunnel) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// v1alpha1 is only converted, the defaulting and validation happen on v1beta1, which every request is converted to
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CloudflareVirtualNetworkSpec defines the desired state of CloudflareVirtualNetwork
+type CloudflareVirtualNetworkSpec struct {
+	// RemoteName is the name of the virtual network in the remote, defaults to `<namespace>-<name>`
+	// +kubebuilder:validation:Optional
+	RemoteName string `json:"remoteName,omitempty"`
+	// +kubebuilder:validation:Optional
+	Comment string `json:"comment,omitempty"`
+	// +kubebuilder:validation:Optional
+	IsDefault       bool   `json:"isDefault"`
+	TokenSecretName string `json:"tokenSecretName"`
+}
+
+// CloudflareVirtualNetworkStatus defines the observed state of CloudflareVirtualNetwork
+type CloudflareVirtualNetworkStatus struct {
+	// +kubebuilder:validation:Format="uuid"
+	VirtualNetworkID string `json:"virtualNetworkID,omitempty"`
+	RemoteName       string `json:"remoteName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Remote Name",type=string,JSONPath=`.status.remoteName`
+//+kubebuilder:printcolumn:name="ID",type=string,JSONPath=`.status.virtualNetworkID`
+
+// CloudflareVirtualNetwork is the Schema for the cloudflarevirtualnetworks API
+type CloudflareVirtualNetwork struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudflareVirtualNetworkSpec   `json:"spec,omitempty"`
+	Status CloudflareVirtualNetworkStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CloudflareVirtualNetworkList contains a list of CloudflareVirtualNetwork
+type CloudflareVirtualNetworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudflareVirtualNetwork `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudflareVirtualNetwork{}, &CloudflareVirtualNetworkList{})
+}
@@ -22,9 +22,120 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessServiceToken) DeepCopyInto(out *AccessServiceToken) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessServiceToken.
+func (in *AccessServiceToken) DeepCopy() *AccessServiceToken {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessServiceToken)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AccessServiceToken) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessServiceTokenList) DeepCopyInto(out *AccessServiceTokenList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AccessServiceToken, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessServiceTokenList.
+func (in *AccessServiceTokenList) DeepCopy() *AccessServiceTokenList {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessServiceTokenList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AccessServiceTokenList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessServiceTokenSpec) DeepCopyInto(out *AccessServiceTokenSpec) {
+	*out = *in
+	if in.RenewBefore != nil {
+		in, out := &in.RenewBefore, &out.RenewBefore
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Overlap != nil {
+		in, out := &in.Overlap, &out.Overlap
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessServiceTokenSpec.
+func (in *AccessServiceTokenSpec) DeepCopy() *AccessServiceTokenSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessServiceTokenSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessServiceTokenStatus) DeepCopyInto(out *AccessServiceTokenStatus) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.PreviousTokenDeleteAt != nil {
+		in, out := &in.PreviousTokenDeleteAt, &out.PreviousTokenDeleteAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessServiceTokenStatus.
+func (in *AccessServiceTokenStatus) DeepCopy() *AccessServiceTokenStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessServiceTokenStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudflareTunnel) DeepCopyInto(out *CloudflareTunnel) {
 	*out = *in
@@ -52,6 +163,135 @@ func (in *CloudflareTunnel) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccess) DeepCopyInto(out *CloudflareTunnelAccess) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]CloudflareTunnelAccessPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccess.
+func (in *CloudflareTunnelAccess) DeepCopy() *CloudflareTunnelAccess {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccessPolicy) DeepCopyInto(out *CloudflareTunnelAccessPolicy) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = new(CloudflareTunnelAccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = new(CloudflareTunnelAccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Require != nil {
+		in, out := &in.Require, &out.Require
+		*out = new(CloudflareTunnelAccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccessPolicy.
+func (in *CloudflareTunnelAccessPolicy) DeepCopy() *CloudflareTunnelAccessPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccessPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccessRules) DeepCopyInto(out *CloudflareTunnelAccessRules) {
+	*out = *in
+	if in.Emails != nil {
+		in, out := &in.Emails, &out.Emails
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EmailDomains != nil {
+		in, out := &in.EmailDomains, &out.EmailDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceTokens != nil {
+		in, out := &in.ServiceTokens, &out.ServiceTokens
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceTokenRefs != nil {
+		in, out := &in.ServiceTokenRefs, &out.ServiceTokenRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPRanges != nil {
+		in, out := &in.IPRanges, &out.IPRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccessRules.
+func (in *CloudflareTunnelAccessRules) DeepCopy() *CloudflareTunnelAccessRules {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccessRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAutoscaling) DeepCopyInto(out *CloudflareTunnelAutoscaling) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilizationPercentage != nil {
+		in, out := &in.TargetCPUUtilizationPercentage, &out.TargetCPUUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]v2.MetricSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAutoscaling.
+func (in *CloudflareTunnelAutoscaling) DeepCopy() *CloudflareTunnelAutoscaling {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAutoscaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudflareTunnelConnections) DeepCopyInto(out *CloudflareTunnelConnections) {
 	*out = *in
@@ -93,6 +333,76 @@ func (in *CloudflareTunnelContainer) DeepCopy() *CloudflareTunnelContainer {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelDNS) DeepCopyInto(out *CloudflareTunnelDNS) {
+	*out = *in
+	if in.Proxied != nil {
+		in, out := &in.Proxied, &out.Proxied
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelDNS.
+func (in *CloudflareTunnelDNS) DeepCopy() *CloudflareTunnelDNS {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelDNS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelHighAvailability) DeepCopyInto(out *CloudflareTunnelHighAvailability) {
+	*out = *in
+	if in.PodDisruptionBudget != nil {
+		in, out := &in.PodDisruptionBudget, &out.PodDisruptionBudget
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.SpreadPods != nil {
+		in, out := &in.SpreadPods, &out.SpreadPods
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelHighAvailability.
+func (in *CloudflareTunnelHighAvailability) DeepCopy() *CloudflareTunnelHighAvailability {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelHighAvailability)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelIPRule) DeepCopyInto(out *CloudflareTunnelIPRule) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelIPRule.
+func (in *CloudflareTunnelIPRule) DeepCopy() *CloudflareTunnelIPRule {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelIPRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudflareTunnelList) DeepCopyInto(out *CloudflareTunnelList) {
 	*out = *in
@@ -125,9 +435,336 @@ func (in *CloudflareTunnelList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelMonitoring) DeepCopyInto(out *CloudflareTunnelMonitoring) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Relabelings != nil {
+		in, out := &in.Relabelings, &out.Relabelings
+		*out = make([]CloudflareTunnelRelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelMonitoring.
+func (in *CloudflareTunnelMonitoring) DeepCopy() *CloudflareTunnelMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelOriginAccess) DeepCopyInto(out *CloudflareTunnelOriginAccess) {
+	*out = *in
+	if in.AudTag != nil {
+		in, out := &in.AudTag, &out.AudTag
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelOriginAccess.
+func (in *CloudflareTunnelOriginAccess) DeepCopy() *CloudflareTunnelOriginAccess {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelOriginAccess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelOriginCA) DeepCopyInto(out *CloudflareTunnelOriginCA) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelOriginCA.
+func (in *CloudflareTunnelOriginCA) DeepCopy() *CloudflareTunnelOriginCA {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelOriginCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelOriginRequest) DeepCopyInto(out *CloudflareTunnelOriginRequest) {
+	*out = *in
+	if in.ConnectTimeout != nil {
+		in, out := &in.ConnectTimeout, &out.ConnectTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TLSTimeout != nil {
+		in, out := &in.TLSTimeout, &out.TLSTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TCPKeepAlive != nil {
+		in, out := &in.TCPKeepAlive, &out.TCPKeepAlive
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.NoHappyEyeballs != nil {
+		in, out := &in.NoHappyEyeballs, &out.NoHappyEyeballs
+		*out = new(bool)
+		**out = **in
+	}
+	if in.KeepAliveConnections != nil {
+		in, out := &in.KeepAliveConnections, &out.KeepAliveConnections
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KeepAliveTimeout != nil {
+		in, out := &in.KeepAliveTimeout, &out.KeepAliveTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.NoTLSVerify != nil {
+		in, out := &in.NoTLSVerify, &out.NoTLSVerify
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableChunkedEncoding != nil {
+		in, out := &in.DisableChunkedEncoding, &out.DisableChunkedEncoding
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BastionMode != nil {
+		in, out := &in.BastionMode, &out.BastionMode
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ProxyPort != nil {
+		in, out := &in.ProxyPort, &out.ProxyPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IPRules != nil {
+		in, out := &in.IPRules, &out.IPRules
+		*out = make([]CloudflareTunnelIPRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HTTP2Origin != nil {
+		in, out := &in.HTTP2Origin, &out.HTTP2Origin
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(CloudflareTunnelOriginAccess)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelOriginRequest.
+func (in *CloudflareTunnelOriginRequest) DeepCopy() *CloudflareTunnelOriginRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelOriginRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelPodTemplate) DeepCopyInto(out *CloudflareTunnelPodTemplate) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]corev1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AutomountServiceAccountToken != nil {
+		in, out := &in.AutomountServiceAccountToken, &out.AutomountServiceAccountToken
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelPodTemplate.
+func (in *CloudflareTunnelPodTemplate) DeepCopy() *CloudflareTunnelPodTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelPodTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelPrivateNetwork) DeepCopyInto(out *CloudflareTunnelPrivateNetwork) {
+	*out = *in
+	if in.CIDRs != nil {
+		in, out := &in.CIDRs, &out.CIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelPrivateNetwork.
+func (in *CloudflareTunnelPrivateNetwork) DeepCopy() *CloudflareTunnelPrivateNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelPrivateNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelProbe) DeepCopyInto(out *CloudflareTunnelProbe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelProbe.
+func (in *CloudflareTunnelProbe) DeepCopy() *CloudflareTunnelProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelProbes) DeepCopyInto(out *CloudflareTunnelProbes) {
+	*out = *in
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(CloudflareTunnelProbe)
+		**out = **in
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(CloudflareTunnelProbe)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelProbes.
+func (in *CloudflareTunnelProbes) DeepCopy() *CloudflareTunnelProbes {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelProbes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelRelabelConfig) DeepCopyInto(out *CloudflareTunnelRelabelConfig) {
+	*out = *in
+	if in.SourceLabels != nil {
+		in, out := &in.SourceLabels, &out.SourceLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelRelabelConfig.
+func (in *CloudflareTunnelRelabelConfig) DeepCopy() *CloudflareTunnelRelabelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelRelabelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelRoute) DeepCopyInto(out *CloudflareTunnelRoute) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelRoute.
+func (in *CloudflareTunnelRoute) DeepCopy() *CloudflareTunnelRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudflareTunnelService) DeepCopyInto(out *CloudflareTunnelService) {
 	*out = *in
+	if in.SocketVolume != nil {
+		in, out := &in.SocketVolume, &out.SocketVolume
+		*out = new(CloudflareTunnelSocketVolume)
+		**out = **in
+	}
+	if in.OriginRequest != nil {
+		in, out := &in.OriginRequest, &out.OriginRequest
+		*out = new(CloudflareTunnelOriginRequest)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelService.
@@ -140,19 +777,89 @@ func (in *CloudflareTunnelService) DeepCopy() *CloudflareTunnelService {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelSocketVolume) DeepCopyInto(out *CloudflareTunnelSocketVolume) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelSocketVolume.
+func (in *CloudflareTunnelSocketVolume) DeepCopy() *CloudflareTunnelSocketVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelSocketVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CloudflareTunnelSpec) DeepCopyInto(out *CloudflareTunnelSpec) {
 	*out = *in
 	if in.Service != nil {
 		in, out := &in.Service, &out.Service
 		*out = new(CloudflareTunnelService)
-		**out = **in
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CatchAll != nil {
+		in, out := &in.CatchAll, &out.CatchAll
+		*out = new(CloudflareTunnelService)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(CloudflareTunnelDNS)
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Container != nil {
 		in, out := &in.Container, &out.Container
 		*out = new(CloudflareTunnelContainer)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(CloudflareTunnelAutoscaling)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(CloudflareTunnelPodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HighAvailability != nil {
+		in, out := &in.HighAvailability, &out.HighAvailability
+		*out = new(CloudflareTunnelHighAvailability)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(CloudflareTunnelMonitoring)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(CloudflareTunnelProbes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OriginRequest != nil {
+		in, out := &in.OriginRequest, &out.OriginRequest
+		*out = new(CloudflareTunnelOriginRequest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OriginCA != nil {
+		in, out := &in.OriginCA, &out.OriginCA
+		*out = new(CloudflareTunnelOriginCA)
+		**out = **in
+	}
+	if in.PrivateNetwork != nil {
+		in, out := &in.PrivateNetwork, &out.PrivateNetwork
+		*out = new(CloudflareTunnelPrivateNetwork)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(CloudflareTunnelAccess)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelSpec.
@@ -175,6 +882,18 @@ func (in *CloudflareTunnelStatus) DeepCopyInto(out *CloudflareTunnelStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]CloudflareTunnelRoute, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelStatus.
@@ -186,3 +905,92 @@ func (in *CloudflareTunnelStatus) DeepCopy() *CloudflareTunnelStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareVirtualNetwork) DeepCopyInto(out *CloudflareVirtualNetwork) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareVirtualNetwork.
+func (in *CloudflareVirtualNetwork) DeepCopy() *CloudflareVirtualNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareVirtualNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareVirtualNetwork) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareVirtualNetworkList) DeepCopyInto(out *CloudflareVirtualNetworkList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudflareVirtualNetwork, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareVirtualNetworkList.
+func (in *CloudflareVirtualNetworkList) DeepCopy() *CloudflareVirtualNetworkList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareVirtualNetworkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareVirtualNetworkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareVirtualNetworkSpec) DeepCopyInto(out *CloudflareVirtualNetworkSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareVirtualNetworkSpec.
+func (in *CloudflareVirtualNetworkSpec) DeepCopy() *CloudflareVirtualNetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareVirtualNetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareVirtualNetworkStatus) DeepCopyInto(out *CloudflareVirtualNetworkStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareVirtualNetworkStatus.
+func (in *CloudflareVirtualNetworkStatus) DeepCopy() *CloudflareVirtualNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareVirtualNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
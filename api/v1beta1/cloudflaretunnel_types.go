@@ -0,0 +1,548 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// CloudflareTunnelSpec defines the desired state of CloudflareTunnel
+type CloudflareTunnelSpec struct {
+	// Zone the hostnames of the ingress rules belong to
+	Zone string `json:"zone"`
+	// Ingress rules are matched in order, only the last rule may leave out the hostname to catch all remaining
+	// requests, which otherwise get a 404
+	// +kubebuilder:validation:MinItems=1
+	Ingress []CloudflareTunnelIngressRule `json:"ingress"`
+	// DNS configures the CNAME records created for the hostnames of the ingress rules
+	// +kubebuilder:validation:Optional
+	DNS *CloudflareTunnelDNS `json:"dns,omitempty"`
+	// +kubebuilder:validation:Optional
+	Container *CloudflareTunnelContainer `json:"container,omitempty"`
+	// AccountSecretRef references the Secret with the API token and the id of the Cloudflare account
+	AccountSecretRef CloudflareTunnelAccountSecretRef `json:"accountSecretRef"`
+	// TunnelRef adopts an existing tunnel of the account instead of creating one. A tunnel created by the operator is
+	// deleted along with its DNS records and routes when the resource is deleted, an adopted one follows its
+	// deletionPolicy. A tunnel can only be managed by one resource
+	// +kubebuilder:validation:Optional
+	TunnelRef *CloudflareTunnelRef `json:"tunnelRef,omitempty"`
+	// Replicas is ignored when Autoscaling is set or the WorkloadKind is DaemonSet
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=1
+	Replicas int32 `json:"replicas"`
+	// WorkloadKind is the kind of the workload running the connectors, a DaemonSet runs one on every node matching the
+	// podTemplate
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Deployment;DaemonSet
+	// +kubebuilder:default=Deployment
+	WorkloadKind string `json:"workloadKind,omitempty"`
+	// Autoscaling creates a HorizontalPodAutoscaler for the connectors, which then manages the number of replicas
+	// +kubebuilder:validation:Optional
+	Autoscaling *CloudflareTunnelAutoscaling `json:"autoscaling,omitempty"`
+	// PodTemplate is merged into the pod template of the generated Deployment
+	// +kubebuilder:validation:Optional
+	PodTemplate *CloudflareTunnelPodTemplate `json:"podTemplate,omitempty"`
+	// HighAvailability configures the disruption budget and the spreading of the pods when there is more than one replica
+	// +kubebuilder:validation:Optional
+	HighAvailability *CloudflareTunnelHighAvailability `json:"highAvailability,omitempty"`
+	// Monitoring creates a metrics Service and a monitor of the Prometheus Operator to scrape cloudflared
+	// +kubebuilder:validation:Optional
+	Monitoring *CloudflareTunnelMonitoring `json:"monitoring,omitempty"`
+	// Probes tunes the readiness and liveness probes on the /ready endpoint of cloudflared, the probes are only added
+	// when the args of the container serve the metrics on 0.0.0.0:9090
+	// +kubebuilder:validation:Optional
+	Probes *CloudflareTunnelProbes `json:"probes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=file;token
+	// +kubebuilder:default=file
+	CredentialsType string `json:"credentialsType"`
+	// OriginRequest holds the tunnel-level defaults applied to every ingress rule
+	// +kubebuilder:validation:Optional
+	OriginRequest *CloudflareTunnelOriginRequest `json:"originRequest,omitempty"`
+	// OriginCA references the CA bundle used to verify the origins, it is used as the default caPool
+	// +kubebuilder:validation:Optional
+	OriginCA *CloudflareTunnelOriginCA `json:"originCA,omitempty"`
+	// +kubebuilder:validation:Optional
+	PrivateNetwork *CloudflareTunnelPrivateNetwork `json:"privateNetwork,omitempty"`
+	// Access puts a self-hosted Cloudflare Access application in front of the hostname of the ingress rules, which
+	// therefore may only have a single hostname. Every rule validates the Access token, so the origins cannot be reached
+	// through the tunnel without passing Access
+	// +kubebuilder:validation:Optional
+	Access *CloudflareTunnelAccess `json:"access,omitempty"`
+}
+
+type CloudflareTunnelIngressRule struct {
+	// Hostname matched by the rule, wildcards like `*.example.com` are allowed
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^(\*\.)?([a-zA-Z0-9]([-a-zA-Z0-9]*[a-zA-Z0-9])?\.)+[a-zA-Z]{2,}$`
+	Hostname string                  `json:"hostname,omitempty"`
+	Service  CloudflareTunnelService `json:"service"`
+}
+
+// CloudflareTunnelAccountSecretRef references the keys of a Secret in the namespace of the tunnel
+type CloudflareTunnelAccountSecretRef struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=token
+	TokenKey string `json:"tokenKey,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=accountID
+	AccountIDKey string `json:"accountIDKey,omitempty"`
+}
+
+const (
+	DeletionPolicyRetain = "Retain" // the adopted tunnel is kept when the resource is deleted
+	DeletionPolicyDelete = "Delete" // the adopted tunnel is deleted along with the resource
+)
+
+// CloudflareTunnelRef references an existing tunnel by its id or, when the id is not set, by its name
+type CloudflareTunnelRef struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Format="uuid"
+	ID string `json:"id,omitempty"`
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+	// DeletionPolicy is what happens to the adopted tunnel, its DNS records and routes when the resource is deleted,
+	// they are kept unless set to Delete
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Retain
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+type CloudflareTunnelAccess struct {
+	// Name of the Access application, defaults to the name of the tunnel
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(ns|us|ms|s|m|h)$`
+	SessionDuration string `json:"sessionDuration,omitempty"`
+	// TeamName is used by cloudflared to validate the Access token at the origin, defaults to the team of the account
+	// +kubebuilder:validation:Optional
+	TeamName string `json:"teamName,omitempty"`
+	// Policies are evaluated in the order they are listed
+	// +kubebuilder:validation:MinItems=1
+	Policies []CloudflareTunnelAccessPolicy `json:"policies"`
+}
+
+type CloudflareTunnelAccessPolicy struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Enum=allow;deny;non_identity;bypass
+	Decision string `json:"decision"`
+	// Include is satisfied when any of its rules match
+	// +kubebuilder:validation:Optional
+	Include *CloudflareTunnelAccessRules `json:"include,omitempty"`
+	// Exclude denies the policy when any of its rules match
+	// +kubebuilder:validation:Optional
+	Exclude *CloudflareTunnelAccessRules `json:"exclude,omitempty"`
+	// Require is satisfied only when all of its rules match
+	// +kubebuilder:validation:Optional
+	Require *CloudflareTunnelAccessRules `json:"require,omitempty"`
+}
+
+// CloudflareTunnelAccessRules lists the Access rules of a policy, every entry is a separate rule
+type CloudflareTunnelAccessRules struct {
+	// +kubebuilder:validation:Optional
+	Everyone bool `json:"everyone,omitempty"`
+	// +kubebuilder:validation:Optional
+	Emails []string `json:"emails,omitempty"`
+	// +kubebuilder:validation:Optional
+	EmailDomains []string `json:"emailDomains,omitempty"`
+	// Groups are the IDs of Access groups
+	// +kubebuilder:validation:Optional
+	Groups []string `json:"groups,omitempty"`
+	// ServiceTokens are the IDs of Access service tokens
+	// +kubebuilder:validation:Optional
+	ServiceTokens []string `json:"serviceTokens,omitempty"`
+	// ServiceTokenRefs are the names of AccessServiceTokens in the same namespace, which stay valid across rotations
+	// +kubebuilder:validation:Optional
+	ServiceTokenRefs []string `json:"serviceTokenRefs,omitempty"`
+	// +kubebuilder:validation:Optional
+	AnyValidServiceToken bool `json:"anyValidServiceToken,omitempty"`
+	// +kubebuilder:validation:Optional
+	IPRanges []string `json:"ipRanges,omitempty"`
+}
+
+// CloudflareTunnelPrivateNetwork configures private network routing through the tunnel
+type CloudflareTunnelPrivateNetwork struct {
+	// Enabled turns on warp-routing in cloudflared and the registration of the routes in CIDRs
+	// +kubebuilder:validation:Optional
+	Enabled bool `json:"enabled"`
+	// CIDRs are registered as routes of the tunnel, e.g. the pod and service CIDRs of the cluster
+	// +kubebuilder:validation:Optional
+	CIDRs []string `json:"cidrs,omitempty"`
+	// VirtualNetworkRef is the name of a CloudflareVirtualNetwork, in the same namespace, the routes are registered in
+	// +kubebuilder:validation:Optional
+	VirtualNetworkRef string `json:"virtualNetworkRef,omitempty"`
+}
+
+// CloudflareTunnelOriginCA references a key in either a Secret or a ConfigMap in the namespace of the tunnel
+type CloudflareTunnelOriginCA struct {
+	// +kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+	// +kubebuilder:validation:Optional
+	ConfigMapName string `json:"configMapName,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=ca.crt
+	Key string `json:"key"`
+}
+
+// protocols of services that are not reached over the network
+const (
+	ProtocolUnix       = "unix"
+	ProtocolHTTPStatus = "http_status"
+	ProtocolHelloWorld = "hello_world"
+)
+
+type CloudflareTunnelService struct {
+	// Name of the Service to target, used when host is not set
+	// +kubebuilder:validation:Optional
+	Name string `json:"name"`
+	// Namespace of the Service, a namespace other than the one of the tunnel needs a ReferenceGrant allowing it
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace"`
+	// Host targets an arbitrary hostname or IP instead of a Service. A host reaching a Service of another namespace
+	// through the DNS of the cluster, like `<name>.<namespace>.svc` or `<name>.<namespace>`, needs a ReferenceGrant as
+	// well. A fully qualified host ending in a dot is never looked up as `<name>.<namespace>`. IPs are not checked
+	// against any ReferenceGrant, restrict the cluster IPs cloudflared may reach with a NetworkPolicy instead
+	// +kubebuilder:validation:Optional
+	Host string `json:"host,omitempty"`
+	// Protocol defaults to unix when path is set, http_status when statusCode is set and http otherwise
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=http;https;tcp;ssh;rdp;smb;unix;http_status;hello_world
+	Protocol string `json:"protocol,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+	// Path of the unix socket when protocol is unix
+	// +kubebuilder:validation:Optional
+	Path string `json:"path,omitempty"`
+	// SocketVolume is mounted at the directory of path so that the unix socket is reachable by cloudflared
+	// +kubebuilder:validation:Optional
+	SocketVolume *CloudflareTunnelSocketVolume `json:"socketVolume,omitempty"`
+	// StatusCode is the static response when protocol is http_status
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	StatusCode int32 `json:"statusCode,omitempty"`
+	// OriginRequest overrides the tunnel-level originRequest defaults for this ingress rule
+	// +kubebuilder:validation:Optional
+	OriginRequest *CloudflareTunnelOriginRequest `json:"originRequest,omitempty"`
+}
+
+// CloudflareTunnelSocketVolume is the volume shared with the process listening on a unix socket
+type CloudflareTunnelSocketVolume struct {
+	// +kubebuilder:validation:Optional
+	HostPath string `json:"hostPath,omitempty"`
+	// +kubebuilder:validation:Optional
+	ClaimName string `json:"claimName,omitempty"`
+}
+
+// CloudflareTunnelOriginRequest mirrors the originRequest settings of cloudflared
+// see https://developers.cloudflare.com/cloudflare-one/connections/connect-apps/configuration/local-management/ingress/#origin-configuration
+type CloudflareTunnelOriginRequest struct {
+	// +kubebuilder:validation:Optional
+	ConnectTimeout *metav1.Duration `json:"connectTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	TLSTimeout *metav1.Duration `json:"tlsTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	TCPKeepAlive *metav1.Duration `json:"tcpKeepAlive,omitempty"`
+	// +kubebuilder:validation:Optional
+	NoHappyEyeballs *bool `json:"noHappyEyeballs,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	KeepAliveConnections *int32 `json:"keepAliveConnections,omitempty"`
+	// +kubebuilder:validation:Optional
+	KeepAliveTimeout *metav1.Duration `json:"keepAliveTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	HTTPHostHeader string `json:"httpHostHeader,omitempty"`
+	// OriginServerName defaults to the hostname of a rule, unless it is set for the whole tunnel
+	// +kubebuilder:validation:Optional
+	OriginServerName string `json:"originServerName,omitempty"`
+	// CAPool is the path, inside the cloudflared container, of the CA bundle used to verify the origin
+	// +kubebuilder:validation:Optional
+	CAPool string `json:"caPool,omitempty"`
+	// +kubebuilder:validation:Optional
+	NoTLSVerify *bool `json:"noTLSVerify,omitempty"`
+	// +kubebuilder:validation:Optional
+	DisableChunkedEncoding *bool `json:"disableChunkedEncoding,omitempty"`
+	// +kubebuilder:validation:Optional
+	BastionMode *bool `json:"bastionMode,omitempty"`
+	// +kubebuilder:validation:Optional
+	ProxyAddress string `json:"proxyAddress,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	ProxyPort *int32 `json:"proxyPort,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum="";socks
+	ProxyType string `json:"proxyType,omitempty"`
+	// +kubebuilder:validation:Optional
+	IPRules []CloudflareTunnelIPRule `json:"ipRules,omitempty"`
+	// +kubebuilder:validation:Optional
+	HTTP2Origin *bool `json:"http2Origin,omitempty"`
+	// +kubebuilder:validation:Optional
+	Access *CloudflareTunnelOriginAccess `json:"access,omitempty"`
+}
+
+type CloudflareTunnelIPRule struct {
+	// +kubebuilder:validation:Optional
+	Prefix string `json:"prefix,omitempty"`
+	// +kubebuilder:validation:Optional
+	Ports []int32 `json:"ports,omitempty"`
+	// +kubebuilder:validation:Optional
+	Allow bool `json:"allow,omitempty"`
+}
+
+// CloudflareTunnelOriginAccess configures the validation of the Cf-Access-Jwt-Assertion header by cloudflared
+type CloudflareTunnelOriginAccess struct {
+	// +kubebuilder:validation:Optional
+	Required bool `json:"required,omitempty"`
+	// +kubebuilder:validation:Optional
+	TeamName string `json:"teamName,omitempty"`
+	// +kubebuilder:validation:Optional
+	AudTag []string `json:"audTag,omitempty"`
+}
+
+// CloudflareTunnelDNS holds the options of the CNAME records pointing the hostnames to the tunnel
+type CloudflareTunnelDNS struct {
+	// Proxied routes the traffic through Cloudflare, which is needed for requests to reach the tunnel
+	// +kubebuilder:validation:Optional
+	Proxied *bool `json:"proxied,omitempty"`
+	// TTL of the record in seconds, 1 means automatic and is the only value allowed for proxied records
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	TTL int `json:"ttl,omitempty"`
+}
+
+// MetricsPort is the port cloudflared serves its metrics and the /ready endpoint on
+const MetricsPort = 9090
+
+type CloudflareTunnelContainer struct {
+	// +kubebuilder:validation:Optional
+	Image string `json:"image"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=IfNotPresent;Always;Never
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy"`
+	// +kubebuilder:validation:Optional
+	Command []string `json:"command"`
+	// +kubebuilder:validation:Optional
+	Args []string `json:"args"`
+}
+
+// CloudflareTunnelPodTemplate contains the overrides for the pods running cloudflared
+type CloudflareTunnelPodTemplate struct {
+	// Annotations are added to the pods
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Labels are added to the pods, the labels set by the operator cannot be overridden
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Resources of the cloudflared container
+	// +kubebuilder:validation:Optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	// +kubebuilder:validation:Optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// +kubebuilder:validation:Optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// +kubebuilder:validation:Optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// +kubebuilder:validation:Optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// +kubebuilder:validation:Optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// +kubebuilder:validation:Optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// SecurityContext replaces the restricted pod security context set by default
+	// +kubebuilder:validation:Optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+	// ContainerSecurityContext replaces the restricted security context of the cloudflared container set by default
+	// +kubebuilder:validation:Optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+	// AutomountServiceAccountToken is disabled by default since cloudflared does not talk to the cluster
+	// +kubebuilder:validation:Optional
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+}
+
+type CloudflareTunnelHighAvailability struct {
+	// PodDisruptionBudget creates a PodDisruptionBudget for the pods
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	PodDisruptionBudget *bool `json:"podDisruptionBudget,omitempty"`
+	// MaxUnavailable of the PodDisruptionBudget, defaults to 1
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// SpreadPods spreads the pods across nodes and zones, unless an affinity or topology spread constraints are set in
+	// the podTemplate
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	SpreadPods *bool `json:"spreadPods,omitempty"`
+}
+
+type CloudflareTunnelAutoscaling struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// TargetCPUUtilizationPercentage is used when no Metrics are given, defaults to 80. The utilization is relative to
+	// the cpu request of the container, which defaults to 100m when unset
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// Metrics replace the cpu target, e.g. to scale on the request rate of cloudflared through a custom metric
+	// +kubebuilder:validation:Optional
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+}
+
+type CloudflareTunnelMonitoring struct {
+	// Kind of the monitor, it is only created when the CRDs of the Prometheus Operator are installed
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=ServiceMonitor;PodMonitor
+	// +kubebuilder:default=ServiceMonitor
+	Kind string `json:"kind,omitempty"`
+	// Interval between scrapes, defaults to the interval of the Prometheus
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^(0|(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?)$`
+	Interval string `json:"interval,omitempty"`
+	// Labels are added to the monitor, e.g. to match the monitor selector of the Prometheus
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// Relabelings are applied after the tunnel and tunnel_id labels have been attached
+	// +kubebuilder:validation:Optional
+	Relabelings []CloudflareTunnelRelabelConfig `json:"relabelings,omitempty"`
+}
+
+// CloudflareTunnelRelabelConfig is a relabel config as used by the Prometheus Operator
+type CloudflareTunnelRelabelConfig struct {
+	// +kubebuilder:validation:Optional
+	SourceLabels []string `json:"sourceLabels,omitempty"`
+	// +kubebuilder:validation:Optional
+	Separator string `json:"separator,omitempty"`
+	// +kubebuilder:validation:Optional
+	TargetLabel string `json:"targetLabel,omitempty"`
+	// +kubebuilder:validation:Optional
+	Regex string `json:"regex,omitempty"`
+	// +kubebuilder:validation:Optional
+	Modulus uint64 `json:"modulus,omitempty"`
+	// +kubebuilder:validation:Optional
+	Replacement string `json:"replacement,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=replace;Replace;keep;Keep;drop;Drop;hashmod;HashMod;labelmap;LabelMap;labeldrop;LabelDrop;labelkeep;LabelKeep
+	Action string `json:"action,omitempty"`
+}
+
+type CloudflareTunnelProbes struct {
+	// +kubebuilder:validation:Optional
+	Readiness *CloudflareTunnelProbe `json:"readiness,omitempty"`
+	// +kubebuilder:validation:Optional
+	Liveness *CloudflareTunnelProbe `json:"liveness,omitempty"`
+}
+
+// CloudflareTunnelProbe contains the thresholds of a probe, unset values use the defaults of Kubernetes
+type CloudflareTunnelProbe struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+	// SuccessThreshold must be 1 for the liveness probe
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+}
+
+// CloudflareTunnelStatus defines the observed state of CloudflareTunnel
+type CloudflareTunnelStatus struct {
+	// +kubebuilder:validation:Format="uuid"
+	TunnelID string `json:"tunnelID,omitempty"`
+	// RemoteName is the name of the tunnel in the remote, kept once chosen so that the tunnel is not renamed when
+	// the name template of the operator changes
+	RemoteName string `json:"remoteName,omitempty"`
+	// Adopted is set when the tunnel was not created by the operator, but adopted through the tunnelRef or found by
+	// the name the operator would have given it. An adopted tunnel is only deleted along with the resource when the
+	// deletionPolicy of the tunnelRef is Delete
+	Adopted     bool                          `json:"adopted,omitempty"`
+	Connections []CloudflareTunnelConnections `json:"connections"`
+	// Routes are the private network routes registered for the tunnel by the operator
+	Routes []CloudflareTunnelRoute `json:"routes,omitempty"`
+	// +kubebuilder:validation:Format="uuid"
+	AccessApplicationID string `json:"accessApplicationID,omitempty"`
+	// AccessApplicationAUD is the audience tag of the Access application
+	AccessApplicationAUD string `json:"accessApplicationAUD,omitempty"`
+	// Conditions contains the Ready condition, which reflects the readiness of the cloudflared pods
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type CloudflareTunnelRoute struct {
+	Network          string `json:"network"`
+	VirtualNetworkID string `json:"virtualNetworkID,omitempty"`
+}
+
+type CloudflareTunnelConnections struct {
+	ConnectorID  string      `json:"connectorID,omitempty"`
+	Created      metav1.Time `json:"created,omitempty"`
+	Architecture string      `json:"architecture,omitempty"`
+	Version      string      `json:"version,omitempty"`
+	OriginIP     string      `json:"originIP,omitempty"`
+	Edge         string      `json:"edge,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:storageversion
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// CloudflareTunnel is the Schema for the cloudflaretunnels API
+type CloudflareTunnel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CloudflareTunnelSpec   `json:"spec,omitempty"`
+	Status CloudflareTunnelStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CloudflareTunnelList contains a list of CloudflareTunnel
+type CloudflareTunnelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CloudflareTunnel `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CloudflareTunnel{}, &CloudflareTunnelList{})
+}
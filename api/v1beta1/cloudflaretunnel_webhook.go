@@ -0,0 +1,487 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var cloudflaretunnellog = logf.Log.WithName("cloudflaretunnel-resource")
+
+const (
+	// DefaultTokenKey and DefaultAccountIDKey are the keys of the account secret unless specified otherwise
+	DefaultTokenKey     = "token"
+	DefaultAccountIDKey = "accountID"
+)
+
+const (
+	defaultImage           = "cloudflare/cloudflared:latest"
+	defaultImagePullPolicy = corev1.PullAlways
+	defaultCommand         = "cloudflared"
+	defaultProtocol        = "http"
+	defaultTTL             = 1 // automatic
+	defaultCPURequest      = "100m"
+)
+
+func (r *CloudflareTunnel) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	// the validation needs to look at other resources, so a custom validator with access to the client is used
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&cloudflareTunnelValidator{client: mgr.GetClient(), apiReader: mgr.GetAPIReader()}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-cloudflare-tunnel-operator-beezlabs-app-v1beta1-cloudflaretunnel,mutating=true,failurePolicy=fail,sideEffects=None,groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflaretunnels,verbs=create;update,versions=v1beta1,name=mcloudflaretunnel.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &CloudflareTunnel{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *CloudflareTunnel) Default() {
+	cloudflaretunnellog.Info("default", "name", r.Name)
+	r.Spec.Default(r.Namespace)
+}
+
+// Default fills in the values the operator would otherwise assume for the unset fields of the spec, so that the
+// effective configuration is visible on the resource. The controller applies it too for when the webhook is disabled.
+func (s *CloudflareTunnelSpec) Default(namespace string) {
+	if s.Container == nil {
+		s.Container = &CloudflareTunnelContainer{}
+	}
+	if s.Container.Image == "" {
+		s.Container.Image = defaultImage
+	}
+	if s.Container.ImagePullPolicy == "" {
+		s.Container.ImagePullPolicy = defaultImagePullPolicy
+	}
+	// the args of a custom command are left alone since they are passed to something other than cloudflared
+	if len(s.Container.Command) == 0 {
+		s.Container.Command = []string{defaultCommand}
+		if len(s.Container.Args) == 0 {
+			// the metrics server also serves /ready, so it has to be reachable by the kubelet
+			s.Container.Args = []string{"tunnel", "--metrics", "0.0.0.0:" + strconv.Itoa(MetricsPort), "--no-autoupdate", "--config", "/config/config.yaml", "run"}
+		}
+	}
+
+	for i := range s.Ingress {
+		s.Ingress[i].Service.Default(namespace)
+	}
+	if s.AccountSecretRef.TokenKey == "" {
+		s.AccountSecretRef.TokenKey = DefaultTokenKey
+	}
+	if s.AccountSecretRef.AccountIDKey == "" {
+		s.AccountSecretRef.AccountIDKey = DefaultAccountIDKey
+	}
+
+	if s.DNS == nil {
+		s.DNS = &CloudflareTunnelDNS{}
+	}
+	if s.DNS.Proxied == nil {
+		proxied := true
+		s.DNS.Proxied = &proxied
+	}
+	if s.DNS.TTL == 0 {
+		s.DNS.TTL = defaultTTL
+	}
+
+	if s.TunnelRef != nil && s.TunnelRef.DeletionPolicy == "" {
+		s.TunnelRef.DeletionPolicy = DeletionPolicyRetain
+	}
+
+	// the utilization is relative to the requests of the pods, without a cpu request the autoscaler never scales
+	if s.Autoscaling != nil && s.Autoscaling.scalesOnUtilization(corev1.ResourceCPU) {
+		if s.PodTemplate == nil {
+			s.PodTemplate = &CloudflareTunnelPodTemplate{}
+		}
+		if _, ok := s.PodTemplate.Resources.Requests[corev1.ResourceCPU]; !ok {
+			if s.PodTemplate.Resources.Requests == nil {
+				s.PodTemplate.Resources.Requests = corev1.ResourceList{}
+			}
+			s.PodTemplate.Resources.Requests[corev1.ResourceCPU] = resource.MustParse(defaultCPURequest)
+		}
+	}
+}
+
+// Hostnames returns the distinct hostnames of the ingress rules in their order
+func (s *CloudflareTunnelSpec) Hostnames() []string {
+	var hostnames []string
+	seen := map[string]bool{}
+	for _, rule := range s.Ingress {
+		hostname := normalizeHostname(rule.Hostname)
+		if hostname == "" || seen[hostname] {
+			continue
+		}
+		seen[hostname] = true
+		hostnames = append(hostnames, rule.Hostname)
+	}
+	return hostnames
+}
+
+// ServesMetrics tells whether the args make cloudflared serve its metrics, and with them the /ready endpoint, on the
+// metrics port of every interface, which the probes of the kubelet rely on
+func (c *CloudflareTunnelContainer) ServesMetrics() bool {
+	for i, arg := range c.Args {
+		var address string
+		switch {
+		case arg == "--metrics" && i+1 < len(c.Args):
+			address = c.Args[i+1]
+		case strings.HasPrefix(arg, "--metrics="):
+			address = strings.TrimPrefix(arg, "--metrics=")
+		default:
+			continue
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || port != strconv.Itoa(MetricsPort) {
+			return false
+		}
+		return host == "" || host == "0.0.0.0" || host == "::"
+	}
+	return false
+}
+
+// scalesOnUtilization tells whether the autoscaler targets the utilization of the given resource, which is the case
+// for the cpu when no metrics are given
+func (a *CloudflareTunnelAutoscaling) scalesOnUtilization(name corev1.ResourceName) bool {
+	if len(a.Metrics) == 0 {
+		return name == corev1.ResourceCPU
+	}
+	for _, metric := range a.Metrics {
+		if metric.Type == autoscalingv2.ResourceMetricSourceType && metric.Resource != nil &&
+			metric.Resource.Name == name && metric.Resource.Target.Type == autoscalingv2.UtilizationMetricType {
+			return true
+		}
+	}
+	return false
+}
+
+// Default fills in the protocol and, for services of the cluster, the namespace of the tunnel
+func (s *CloudflareTunnelService) Default(namespace string) {
+	if s.Protocol == "" {
+		switch {
+		case s.Path != "":
+			s.Protocol = ProtocolUnix
+		case s.StatusCode != 0:
+			s.Protocol = ProtocolHTTPStatus
+		default:
+			s.Protocol = defaultProtocol
+		}
+	}
+	// the namespace only matters for services looked up in the cluster
+	if s.Namespace == "" && s.Host == "" && s.Name != "" {
+		s.Namespace = namespace
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-cloudflare-tunnel-operator-beezlabs-app-v1beta1-cloudflaretunnel,mutating=false,failurePolicy=fail,sideEffects=None,groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflaretunnels,verbs=create;update,versions=v1beta1,name=vcloudflaretunnel.kb.io,admissionReviewVersions=v1
+
+type cloudflareTunnelValidator struct {
+	client    client.Client
+	apiReader client.Reader // looks up the services of hosts, including the ones of namespaces the cache doesn't watch
+}
+
+var _ webhook.CustomValidator = &cloudflareTunnelValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *cloudflareTunnelValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	cloudflareTunnel, ok := obj.(*CloudflareTunnel)
+	if !ok {
+		return fmt.Errorf("expected a CloudflareTunnel but got a %T", obj)
+	}
+	cloudflaretunnellog.Info("validate create", "name", cloudflareTunnel.Name)
+	return v.validate(ctx, cloudflareTunnel, nil)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *cloudflareTunnelValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	cloudflareTunnel, ok := newObj.(*CloudflareTunnel)
+	if !ok {
+		return fmt.Errorf("expected a CloudflareTunnel but got a %T", newObj)
+	}
+	cloudflaretunnellog.Info("validate update", "name", cloudflareTunnel.Name)
+	// a resource being deleted must not be stuck on a finalizer because of a spec that became invalid in the meantime
+	if !cloudflareTunnel.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	oldCloudflareTunnel, ok := oldObj.(*CloudflareTunnel)
+	if !ok {
+		return fmt.Errorf("expected a CloudflareTunnel but got a %T", oldObj)
+	}
+	return v.validate(ctx, cloudflareTunnel, oldCloudflareTunnel)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type
+func (v *cloudflareTunnelValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+// validate checks the tunnel, old is the tunnel before an update and nil on create
+func (v *cloudflareTunnelValidator) validate(ctx context.Context, cloudflareTunnel *CloudflareTunnel, old *CloudflareTunnel) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	spec := cloudflareTunnel.Spec
+
+	zone := normalizeHostname(spec.Zone)
+	ingressPath := specPath.Child("ingress")
+	for i, rule := range spec.Ingress {
+		rulePath := ingressPath.Index(i)
+		if rule.Hostname == "" {
+			// a rule without a hostname matches everything, so any rule after it would be unreachable
+			if i != len(spec.Ingress)-1 {
+				allErrs = append(allErrs, field.Required(rulePath.Child("hostname"), "only the last rule may leave out the hostname"))
+			}
+		} else {
+			hostname := strings.TrimPrefix(normalizeHostname(rule.Hostname), "*.")
+			if hostname != zone && !strings.HasSuffix(hostname, "."+zone) {
+				allErrs = append(allErrs, field.Invalid(rulePath.Child("hostname"), rule.Hostname, "hostname is not part of the zone "+spec.Zone))
+			}
+		}
+	}
+	if spec.AccountSecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("accountSecretRef", "name"), "the secret with the credentials of the account is required"))
+	}
+	if spec.TunnelRef != nil && spec.TunnelRef.ID == "" && spec.TunnelRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("tunnelRef"), "the id or the name of the tunnel to adopt is required"))
+	}
+	if spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), spec.Replicas, "replicas must not be negative"))
+	}
+	if spec.Container != nil {
+		allErrs = append(allErrs, validateContainer(specPath.Child("container"), spec.Container)...)
+	}
+	if spec.Access != nil {
+		// an Access application covers a single domain, any other hostname would be reachable without passing Access
+		if hostnames := spec.Hostnames(); len(hostnames) != 1 {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("access"), strings.Join(hostnames, ", "),
+				"the Access application needs the ingress rules to have exactly one hostname"))
+		}
+	}
+	if spec.Probes != nil && (spec.Container == nil || !spec.Container.ServesMetrics()) {
+		// without the metrics server the probes would fail and the connectors would be restarted forever
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("probes"),
+			"the probes need the args of the container to contain --metrics 0.0.0.0:"+strconv.Itoa(MetricsPort)))
+	}
+	if spec.Autoscaling != nil {
+		allErrs = append(allErrs, validateAutoscaling(specPath, &spec)...)
+	}
+
+	hostnameErrs, err := v.validateUniqueHostnames(ctx, cloudflareTunnel, ingressPath)
+	if err != nil {
+		return err
+	}
+	allErrs = append(allErrs, hostnameErrs...)
+
+	tunnelRefErrs, err := v.validateUniqueTunnelRef(ctx, cloudflareTunnel, specPath.Child("tunnelRef"))
+	if err != nil {
+		return err
+	}
+	allErrs = append(allErrs, tunnelRefErrs...)
+
+	referenceErrs, err := v.validateReferences(ctx, cloudflareTunnel, old, ingressPath)
+	if err != nil {
+		return err
+	}
+	allErrs = append(allErrs, referenceErrs...)
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("CloudflareTunnel").GroupKind(), cloudflareTunnel.Name, allErrs)
+}
+
+// validateContainer rejects commands that would keep cloudflared from picking up the config of the operator
+func validateContainer(path *field.Path, container *CloudflareTunnelContainer) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, command := range container.Command {
+		if strings.TrimSpace(command) == "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("command").Index(i), command, "command must not be empty"))
+		}
+	}
+	for i, arg := range container.Args {
+		if strings.TrimSpace(arg) == "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("args").Index(i), arg, "argument must not be empty"))
+		}
+	}
+	// the args are only checked for the cloudflared binary, a wrapper of a custom command might take anything
+	if len(container.Args) != 0 && (len(container.Command) == 0 || container.Command[0] == "cloudflared") {
+		hasTunnel, hasRun := false, false
+		for _, arg := range container.Args {
+			switch arg {
+			case "tunnel":
+				hasTunnel = true
+			case "run":
+				hasRun = hasTunnel
+			}
+		}
+		if !hasTunnel || !hasRun {
+			allErrs = append(allErrs, field.Invalid(path.Child("args"), container.Args, "cloudflared needs to be run with `tunnel ... run`"))
+		}
+	}
+	return allErrs
+}
+
+// validateAutoscaling makes sure every resource the autoscaler targets the utilization of has a request, which the
+// utilization is relative to
+func validateAutoscaling(specPath *field.Path, spec *CloudflareTunnelSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	var requests corev1.ResourceList
+	if spec.PodTemplate != nil {
+		requests = spec.PodTemplate.Resources.Requests
+	}
+	for i, metric := range spec.Autoscaling.Metrics {
+		if metric.Resource == nil || !spec.Autoscaling.scalesOnUtilization(metric.Resource.Name) {
+			continue
+		}
+		if _, ok := requests[metric.Resource.Name]; !ok {
+			allErrs = append(allErrs, field.Required(specPath.Child("podTemplate", "resources", "requests").Key(string(metric.Resource.Name)),
+				fmt.Sprintf("the request is required for the utilization target of spec.autoscaling.metrics[%d]", i)))
+		}
+	}
+	return allErrs
+}
+
+// validateUniqueHostnames rejects hostnames already used by another tunnel, since both would fight over the same DNS
+// record
+func (v *cloudflareTunnelValidator) validateUniqueHostnames(ctx context.Context, cloudflareTunnel *CloudflareTunnel, path *field.Path) (field.ErrorList, error) {
+	var cloudflareTunnels CloudflareTunnelList
+	if err := v.client.List(ctx, &cloudflareTunnels); err != nil {
+		return nil, err
+	}
+	taken := map[string]bool{}
+	for _, other := range cloudflareTunnels.Items {
+		if other.Namespace == cloudflareTunnel.Namespace && other.Name == cloudflareTunnel.Name {
+			continue
+		}
+		for _, rule := range other.Spec.Ingress {
+			taken[normalizeHostname(rule.Hostname)] = true
+		}
+	}
+
+	var allErrs field.ErrorList
+	seen := map[string]bool{}
+	for i, rule := range cloudflareTunnel.Spec.Ingress {
+		if rule.Hostname == "" {
+			continue
+		}
+		hostname := normalizeHostname(rule.Hostname)
+		if taken[hostname] || seen[hostname] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i).Child("hostname"), rule.Hostname))
+		}
+		seen[hostname] = true
+	}
+	return allErrs, nil
+}
+
+// validateUniqueTunnelRef rejects the adoption of a tunnel that another resource references or manages already
+func (v *cloudflareTunnelValidator) validateUniqueTunnelRef(ctx context.Context, cloudflareTunnel *CloudflareTunnel, path *field.Path) (field.ErrorList, error) {
+	ref := cloudflareTunnel.Spec.TunnelRef
+	if ref == nil {
+		return nil, nil
+	}
+	var cloudflareTunnels CloudflareTunnelList
+	if err := v.client.List(ctx, &cloudflareTunnels); err != nil {
+		return nil, err
+	}
+	var allErrs field.ErrorList
+	for _, other := range cloudflareTunnels.Items {
+		if other.Namespace == cloudflareTunnel.Namespace && other.Name == cloudflareTunnel.Name {
+			continue
+		}
+		// the ids are uuids, which are case insensitive
+		ids := []string{strings.ToLower(other.Status.TunnelID)}
+		names := []string{other.Status.RemoteName}
+		if other.Spec.TunnelRef != nil {
+			ids = append(ids, strings.ToLower(other.Spec.TunnelRef.ID))
+			names = append(names, other.Spec.TunnelRef.Name)
+		}
+		if ref.ID != "" && contains(ids, strings.ToLower(ref.ID)) {
+			allErrs = append(allErrs, field.Duplicate(path.Child("id"), ref.ID))
+		}
+		if ref.Name != "" && contains(names, ref.Name) {
+			allErrs = append(allErrs, field.Duplicate(path.Child("name"), ref.Name))
+		}
+	}
+	return allErrs, nil
+}
+
+// contains checks if the values contain the value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validateReferences rejects services of other namespaces that no ReferenceGrant allows the tunnel to target. The
+// services the tunnel already had before an update are left to the controller, which cuts them off once their grant
+// is revoked, so that the tunnel can still be updated in the meantime
+func (v *cloudflareTunnelValidator) validateReferences(ctx context.Context, cloudflareTunnel *CloudflareTunnel, old *CloudflareTunnel, path *field.Path) (field.ErrorList, error) {
+	existing := map[types.NamespacedName]bool{}
+	if old != nil {
+		for _, rule := range old.Spec.Ingress {
+			// a target that can't be looked up is checked like a new one
+			if target, ok, err := rule.Service.TargetService(ctx, v.apiReader, old.Namespace); err == nil && ok {
+				existing[target] = true
+			}
+		}
+	}
+
+	var allErrs field.ErrorList
+	for i, rule := range cloudflareTunnel.Spec.Ingress {
+		target, ok, err := rule.Service.TargetService(ctx, v.apiReader, cloudflareTunnel.Namespace)
+		if err != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Index(i).Child("service", "host"), fmt.Sprintf(
+				"%v, end the host with a dot if it is an external one", err)))
+			continue
+		}
+		if !ok || existing[target] {
+			continue
+		}
+		permitted, err := ReferencePermitted(ctx, v.client, cloudflareTunnel.Namespace, target)
+		if err != nil {
+			return nil, err
+		}
+		if !permitted {
+			allErrs = append(allErrs, field.Forbidden(path.Index(i).Child("service"), fmt.Sprintf(
+				"no ReferenceGrant in namespace %s allows tunnels from namespace %s to reference service %s",
+				target.Namespace, cloudflareTunnel.Namespace, target.Name)))
+		}
+	}
+	return allErrs, nil
+}
+
+// normalizeHostname makes hostnames comparable, DNS names are case insensitive and may be fully qualified
+func normalizeHostname(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
@@ -0,0 +1,439 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestCloudflareTunnelSpecDefault(t *testing.T) {
+	trueValue := true
+	defaultArgs := []string{"tunnel", "--metrics", "0.0.0.0:9090", "--no-autoupdate", "--config", "/config/config.yaml", "run"}
+	tests := []struct {
+		name  string
+		spec  CloudflareTunnelSpec
+		check func(t *testing.T, spec CloudflareTunnelSpec)
+	}{
+		{
+			name: "empty spec",
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				want := &CloudflareTunnelContainer{
+					Image:           defaultImage,
+					ImagePullPolicy: defaultImagePullPolicy,
+					Command:         []string{defaultCommand},
+					Args:            defaultArgs,
+				}
+				if !reflect.DeepEqual(spec.Container, want) {
+					t.Errorf("container = %+v, want %+v", spec.Container, want)
+				}
+				if !spec.Container.ServesMetrics() {
+					t.Error("the default args don't serve the metrics")
+				}
+				if spec.AccountSecretRef.TokenKey != DefaultTokenKey || spec.AccountSecretRef.AccountIDKey != DefaultAccountIDKey {
+					t.Errorf("accountSecretRef = %+v, want the default keys", spec.AccountSecretRef)
+				}
+				if want := (&CloudflareTunnelDNS{Proxied: &trueValue, TTL: defaultTTL}); !reflect.DeepEqual(spec.DNS, want) {
+					t.Errorf("dns = %+v, want %+v", spec.DNS, want)
+				}
+				if spec.PodTemplate != nil {
+					t.Errorf("podTemplate = %+v, want none", spec.PodTemplate)
+				}
+			},
+		},
+		{
+			name: "args of a custom command are kept",
+			spec: CloudflareTunnelSpec{Container: &CloudflareTunnelContainer{Command: []string{"/bin/wrapper"}}},
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				if len(spec.Container.Args) != 0 {
+					t.Errorf("args = %v, want none", spec.Container.Args)
+				}
+				if spec.Container.ServesMetrics() {
+					t.Error("a custom command without args serves the metrics")
+				}
+			},
+		},
+		{
+			name: "protocol and namespace of the services",
+			spec: CloudflareTunnelSpec{Ingress: []CloudflareTunnelIngressRule{
+				{Service: CloudflareTunnelService{Name: "app"}},
+				{Service: CloudflareTunnelService{Host: "app.example.com"}},
+				{Service: CloudflareTunnelService{Path: "/run/app.sock"}},
+				{Service: CloudflareTunnelService{StatusCode: 404}},
+			}},
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				want := []CloudflareTunnelService{
+					{Name: "app", Namespace: "default", Protocol: defaultProtocol},
+					{Host: "app.example.com", Protocol: defaultProtocol},
+					{Path: "/run/app.sock", Protocol: ProtocolUnix},
+					{StatusCode: 404, Protocol: ProtocolHTTPStatus},
+				}
+				for i, rule := range spec.Ingress {
+					if !reflect.DeepEqual(rule.Service, want[i]) {
+						t.Errorf("ingress[%d].service = %+v, want %+v", i, rule.Service, want[i])
+					}
+				}
+			},
+		},
+		{
+			name: "deletion policy of the tunnelRef",
+			spec: CloudflareTunnelSpec{TunnelRef: &CloudflareTunnelRef{Name: "existing"}},
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				if spec.TunnelRef.DeletionPolicy != DeletionPolicyRetain {
+					t.Errorf("deletionPolicy = %q, want %q", spec.TunnelRef.DeletionPolicy, DeletionPolicyRetain)
+				}
+			},
+		},
+		{
+			name: "cpu request of the default autoscaling",
+			spec: CloudflareTunnelSpec{Autoscaling: &CloudflareTunnelAutoscaling{MaxReplicas: 3}},
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				cpu, ok := spec.PodTemplate.Resources.Requests[corev1.ResourceCPU]
+				if !ok || !cpu.Equal(resource.MustParse(defaultCPURequest)) {
+					t.Errorf("requests = %v, want cpu %s", spec.PodTemplate.Resources.Requests, defaultCPURequest)
+				}
+			},
+		},
+		{
+			name: "cpu request of the spec is kept",
+			spec: CloudflareTunnelSpec{
+				Autoscaling: &CloudflareTunnelAutoscaling{MaxReplicas: 3},
+				PodTemplate: &CloudflareTunnelPodTemplate{Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")},
+				}},
+			},
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				if cpu := spec.PodTemplate.Resources.Requests[corev1.ResourceCPU]; !cpu.Equal(resource.MustParse("250m")) {
+					t.Errorf("cpu request = %s, want 250m", cpu.String())
+				}
+			},
+		},
+		{
+			name: "no cpu request for other metrics",
+			spec: CloudflareTunnelSpec{Autoscaling: &CloudflareTunnelAutoscaling{
+				MaxReplicas: 3,
+				Metrics: []autoscalingv2.MetricSpec{{
+					Type: autoscalingv2.PodsMetricSourceType,
+					Pods: &autoscalingv2.PodsMetricSource{
+						Metric: autoscalingv2.MetricIdentifier{Name: "cloudflared_tunnel_total_requests"},
+						Target: autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType},
+					},
+				}},
+			}},
+			check: func(t *testing.T, spec CloudflareTunnelSpec) {
+				if spec.PodTemplate != nil {
+					t.Errorf("podTemplate = %+v, want none", spec.PodTemplate)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := *tt.spec.DeepCopy()
+			spec.Default("default")
+			tt.check(t, spec)
+		})
+	}
+}
+
+func TestServesMetrics(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{name: "separate value", args: []string{"tunnel", "--metrics", "0.0.0.0:9090", "run"}, want: true},
+		{name: "inline value", args: []string{"tunnel", "--metrics=0.0.0.0:9090", "run"}, want: true},
+		{name: "every interface without an address", args: []string{"tunnel", "--metrics", ":9090", "run"}, want: true},
+		{name: "localhost only", args: []string{"tunnel", "--metrics", "localhost:9090", "run"}},
+		{name: "other port", args: []string{"tunnel", "--metrics", "0.0.0.0:2000", "run"}},
+		{name: "without metrics", args: []string{"tunnel", "run"}},
+		{name: "metrics without a value", args: []string{"tunnel", "run", "--metrics"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			container := &CloudflareTunnelContainer{Args: tt.args}
+			if got := container.ServesMetrics(); got != tt.want {
+				t.Errorf("ServesMetrics() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// validTunnel returns a tunnel that passes the validation, to be modified by the test cases
+func validTunnel(namespace string, name string) *CloudflareTunnel {
+	tunnel := &CloudflareTunnel{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: CloudflareTunnelSpec{
+			Zone: "example.com",
+			Ingress: []CloudflareTunnelIngressRule{
+				{Hostname: name + ".example.com", Service: CloudflareTunnelService{Name: "app", Port: 80}},
+			},
+			AccountSecretRef: CloudflareTunnelAccountSecretRef{Name: "cloudflare"},
+			Replicas:         1,
+		},
+	}
+	tunnel.Spec.Default(namespace)
+	return tunnel
+}
+
+func TestCloudflareTunnelValidateCreate(t *testing.T) {
+	existing := validTunnel("default", "existing")
+	existing.Spec.TunnelRef = &CloudflareTunnelRef{Name: "adopted"}
+	existing.Status.TunnelID = "f70ff985-a4ef-4643-bbbc-4a0ed4fc8415"
+	existing.Status.RemoteName = "default-existing"
+	granted := &ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "tunnels", Namespace: "granted"},
+		Spec: ReferenceGrantSpec{
+			From: []ReferenceGrantFrom{{Group: GroupVersion.Group, Kind: "CloudflareTunnel", Namespace: "default"}},
+			To:   []ReferenceGrantTo{{Kind: "Service"}},
+		},
+	}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "other"}}
+
+	tests := []struct {
+		name    string
+		modify  func(tunnel *CloudflareTunnel)
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			modify: func(tunnel *CloudflareTunnel) {},
+		},
+		{
+			name: "hostname outside of the zone",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Hostname = "app.example.org"
+			},
+			wantErr: true,
+		},
+		{
+			name: "catch-all rule before another rule",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress = append([]CloudflareTunnelIngressRule{{Service: CloudflareTunnelService{Protocol: ProtocolHelloWorld}}}, tunnel.Spec.Ingress...)
+			},
+			wantErr: true,
+		},
+		{
+			name: "hostname of another tunnel",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Hostname = "Existing.example.com."
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing account secret",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.AccountSecretRef.Name = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "tunnelRef without id and name",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.TunnelRef = &CloudflareTunnelRef{}
+			},
+			wantErr: true,
+		},
+		{
+			name: "tunnelRef of a tunnel adopted by another resource",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.TunnelRef = &CloudflareTunnelRef{Name: "adopted"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "tunnelRef of a tunnel managed by another resource",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.TunnelRef = &CloudflareTunnelRef{ID: "F70FF985-A4EF-4643-BBBC-4A0ED4FC8415"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "tunnelRef of an unmanaged tunnel",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.TunnelRef = &CloudflareTunnelRef{Name: "unmanaged"}
+			},
+		},
+		{
+			name: "access for a single hostname",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Access = &CloudflareTunnelAccess{}
+				tunnel.Spec.Ingress = append(tunnel.Spec.Ingress, CloudflareTunnelIngressRule{Service: CloudflareTunnelService{Protocol: ProtocolHTTPStatus, StatusCode: 404}})
+			},
+		},
+		{
+			name: "access for more than one hostname",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Access = &CloudflareTunnelAccess{}
+				tunnel.Spec.Ingress = append(tunnel.Spec.Ingress, CloudflareTunnelIngressRule{Hostname: "other.example.com", Service: CloudflareTunnelService{Name: "other", Port: 80}})
+			},
+			wantErr: true,
+		},
+		{
+			name: "probes without metrics",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Container.Args = []string{"tunnel", "--no-autoupdate", "run"}
+				tunnel.Spec.Probes = &CloudflareTunnelProbes{}
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom args of cloudflared without run",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Container.Args = []string{"tunnel", "--metrics", "0.0.0.0:9090"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "memory utilization without a memory request",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Autoscaling = &CloudflareTunnelAutoscaling{
+					MaxReplicas: 3,
+					Metrics: []autoscalingv2.MetricSpec{{
+						Type: autoscalingv2.ResourceMetricSourceType,
+						Resource: &autoscalingv2.ResourceMetricSource{
+							Name:   corev1.ResourceMemory,
+							Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType},
+						},
+					}},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "service of another namespace with a grant",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service.Namespace = "granted"
+			},
+		},
+		{
+			name: "service of another namespace without a grant",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service.Namespace = "other"
+			},
+			wantErr: true,
+		},
+		{
+			name: "host of a service of another namespace without a grant",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service = CloudflareTunnelService{Host: "app.other.svc.cluster.local", Protocol: "http", Port: 80}
+			},
+			wantErr: true,
+		},
+		{
+			name: "short host of a service of another namespace without a grant",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service = CloudflareTunnelService{Host: "app.other", Protocol: "http", Port: 80}
+			},
+			wantErr: true,
+		},
+		{
+			name: "fully qualified external host",
+			modify: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service = CloudflareTunnelService{Host: "app.other.", Protocol: "http", Port: 80}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, existing, granted, service)
+			validator := &cloudflareTunnelValidator{client: c, apiReader: c}
+			tunnel := validTunnel("default", "tunnel")
+			tt.modify(tunnel)
+			err := validator.ValidateCreate(context.Background(), tunnel)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCloudflareTunnelValidateUpdate(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name    string
+		old     func(tunnel *CloudflareTunnel)
+		new     func(tunnel *CloudflareTunnel)
+		wantErr bool
+	}{
+		{
+			name: "service of another namespace that was referenced before",
+			old: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service.Namespace = "other"
+			},
+			new: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service.Namespace = "other"
+				tunnel.Spec.Replicas = 2
+			},
+		},
+		{
+			name: "service of another namespace that is new",
+			old:  func(tunnel *CloudflareTunnel) {},
+			new: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.Ingress[0].Service.Namespace = "other"
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid spec being deleted",
+			old: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.AccountSecretRef.Name = ""
+			},
+			new: func(tunnel *CloudflareTunnel) {
+				tunnel.Spec.AccountSecretRef.Name = ""
+				tunnel.DeletionTimestamp = &now
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t)
+			validator := &cloudflareTunnelValidator{client: c, apiReader: c}
+			oldTunnel := validTunnel("default", "tunnel")
+			tt.old(oldTunnel)
+			newTunnel := validTunnel("default", "tunnel")
+			tt.new(newTunnel)
+			err := validator.ValidateUpdate(context.Background(), oldTunnel, newTunnel)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
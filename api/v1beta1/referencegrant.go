@@ -0,0 +1,119 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Allows checks if the grant allows tunnels of the namespace to reference the service
+func (s *ReferenceGrantSpec) Allows(namespace string, serviceName string) bool {
+	fromAllowed := false
+	for _, from := range s.From {
+		if from.Group == GroupVersion.Group && from.Kind == "CloudflareTunnel" && from.Namespace == namespace {
+			fromAllowed = true
+			break
+		}
+	}
+	if !fromAllowed {
+		return false
+	}
+	for _, to := range s.To {
+		if to.Group == "" && to.Kind == "Service" && (to.Name == "" || to.Name == serviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferencePermitted tells whether tunnels of the namespace may target the service, which is always the case for
+// services of the same namespace and needs a ReferenceGrant in the namespace of the service otherwise
+func ReferencePermitted(ctx context.Context, c client.Reader, namespace string, service types.NamespacedName) (bool, error) {
+	if service.Namespace == namespace {
+		return true, nil
+	}
+	var referenceGrants ReferenceGrantList
+	if err := c.List(ctx, &referenceGrants, client.InNamespace(service.Namespace)); err != nil {
+		return false, err
+	}
+	for _, referenceGrant := range referenceGrants.Items {
+		if referenceGrant.Spec.Allows(namespace, service.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TargetService returns the Service of the cluster the rule sends its traffic to, if any. Next to the name and
+// namespace of the spec, a host can reach a Service through the DNS of the cluster as `<name>.<namespace>.svc`,
+// optionally followed by the cluster domain, or as `<name>.<namespace>` when such a Service exists. The reader should
+// not be backed by a cache, which fails for the namespaces it doesn't watch. The lookup failing for any other reason
+// than the Service not being found returns an error, since the host might still be a Service. Hosts given as IP
+// addresses are never taken for a Service, so reaching a Service through its cluster IP bypasses the ReferenceGrants.
+func (s *CloudflareTunnelService) TargetService(ctx context.Context, c client.Reader, namespace string) (types.NamespacedName, bool, error) {
+	switch s.Protocol {
+	case ProtocolHelloWorld, ProtocolHTTPStatus, ProtocolUnix:
+		return types.NamespacedName{}, false, nil
+	}
+	if s.Host == "" {
+		if s.Name == "" {
+			return types.NamespacedName{}, false, nil
+		}
+		service := types.NamespacedName{Name: s.Name, Namespace: s.Namespace}
+		if service.Namespace == "" {
+			service.Namespace = namespace
+		}
+		return service, true, nil
+	}
+
+	if net.ParseIP(strings.Trim(s.Host, "[]")) != nil {
+		return types.NamespacedName{}, false, nil
+	}
+	// a fully qualified host skips the search path of the pods, which is what resolves the shorter forms
+	absolute := strings.HasSuffix(s.Host, ".")
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(s.Host, ".")), ".")
+	switch {
+	case len(labels) >= 3 && labels[2] == "svc":
+		return types.NamespacedName{Name: labels[0], Namespace: labels[1]}, true, nil
+	case absolute:
+		return types.NamespacedName{}, false, nil
+	case len(labels) == 1:
+		// the search path of the pods resolves a single label to a Service of their own namespace
+		return types.NamespacedName{Name: labels[0], Namespace: namespace}, true, nil
+	case len(labels) == 2:
+		// the host might just as well be an external one like `example.com`, it only refers to a Service when one by that
+		// name can be found
+		service := types.NamespacedName{Name: labels[0], Namespace: labels[1]}
+		var existing corev1.Service
+		if err := c.Get(ctx, service, &existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				return types.NamespacedName{}, false, nil
+			}
+			return types.NamespacedName{}, false, fmt.Errorf("could not check if host %s refers to service %s: %w", s.Host, service, err)
+		}
+		return service, true, nil
+	}
+	return types.NamespacedName{}, false, nil
+}
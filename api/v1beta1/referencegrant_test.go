@@ -0,0 +1,233 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestReferenceGrantSpecAllows(t *testing.T) {
+	from := ReferenceGrantFrom{Group: GroupVersion.Group, Kind: "CloudflareTunnel", Namespace: "tunnels"}
+	tests := []struct {
+		name      string
+		spec      ReferenceGrantSpec
+		namespace string
+		service   string
+		want      bool
+	}{
+		{
+			name:      "every service",
+			spec:      ReferenceGrantSpec{From: []ReferenceGrantFrom{from}, To: []ReferenceGrantTo{{Kind: "Service"}}},
+			namespace: "tunnels",
+			service:   "app",
+			want:      true,
+		},
+		{
+			name:      "named service",
+			spec:      ReferenceGrantSpec{From: []ReferenceGrantFrom{from}, To: []ReferenceGrantTo{{Kind: "Service", Name: "app"}}},
+			namespace: "tunnels",
+			service:   "app",
+			want:      true,
+		},
+		{
+			name:      "other service",
+			spec:      ReferenceGrantSpec{From: []ReferenceGrantFrom{from}, To: []ReferenceGrantTo{{Kind: "Service", Name: "app"}}},
+			namespace: "tunnels",
+			service:   "other",
+		},
+		{
+			name:      "other namespace",
+			spec:      ReferenceGrantSpec{From: []ReferenceGrantFrom{from}, To: []ReferenceGrantTo{{Kind: "Service"}}},
+			namespace: "other",
+			service:   "app",
+		},
+		{
+			name: "other kind",
+			spec: ReferenceGrantSpec{
+				From: []ReferenceGrantFrom{{Group: GroupVersion.Group, Kind: "CloudflareVirtualNetwork", Namespace: "tunnels"}},
+				To:   []ReferenceGrantTo{{Kind: "Service"}},
+			},
+			namespace: "tunnels",
+			service:   "app",
+		},
+		{
+			name:      "other group",
+			spec:      ReferenceGrantSpec{From: []ReferenceGrantFrom{from}, To: []ReferenceGrantTo{{Group: "apps", Kind: "Service"}}},
+			namespace: "tunnels",
+			service:   "app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.spec.Allows(tt.namespace, tt.service); got != tt.want {
+				t.Errorf("Allows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferencePermitted(t *testing.T) {
+	grant := &ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: "tunnels", Namespace: "granted"},
+		Spec: ReferenceGrantSpec{
+			From: []ReferenceGrantFrom{{Group: GroupVersion.Group, Kind: "CloudflareTunnel", Namespace: "tunnels"}},
+			To:   []ReferenceGrantTo{{Kind: "Service", Name: "app"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		service types.NamespacedName
+		want    bool
+	}{
+		{name: "same namespace", service: types.NamespacedName{Name: "app", Namespace: "tunnels"}, want: true},
+		{name: "granted service", service: types.NamespacedName{Name: "app", Namespace: "granted"}, want: true},
+		{name: "other service of the granted namespace", service: types.NamespacedName{Name: "other", Namespace: "granted"}},
+		{name: "namespace without a grant", service: types.NamespacedName{Name: "app", Namespace: "other"}},
+	}
+	c := newTestClient(t, grant)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReferencePermitted(context.Background(), c, "tunnels", tt.service)
+			if err != nil {
+				t.Fatalf("ReferencePermitted() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReferencePermitted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetService(t *testing.T) {
+	existing := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "other"},
+		Spec:       corev1.ServiceSpec{ClusterIP: "10.96.0.10"},
+	}
+	tests := []struct {
+		name    string
+		service CloudflareTunnelService
+		want    types.NamespacedName
+		wantOK  bool
+		wantErr bool
+	}{
+		{
+			name:    "name in the namespace of the tunnel",
+			service: CloudflareTunnelService{Name: "app", Protocol: "http"},
+			want:    types.NamespacedName{Name: "app", Namespace: "tunnels"},
+			wantOK:  true,
+		},
+		{
+			name:    "name in another namespace",
+			service: CloudflareTunnelService{Name: "app", Namespace: "other", Protocol: "http"},
+			want:    types.NamespacedName{Name: "app", Namespace: "other"},
+			wantOK:  true,
+		},
+		{
+			name:    "single label host",
+			service: CloudflareTunnelService{Host: "app", Protocol: "http"},
+			want:    types.NamespacedName{Name: "app", Namespace: "tunnels"},
+			wantOK:  true,
+		},
+		{
+			name:    "host of the cluster domain",
+			service: CloudflareTunnelService{Host: "App.Other.svc.cluster.local.", Protocol: "http"},
+			want:    types.NamespacedName{Name: "app", Namespace: "other"},
+			wantOK:  true,
+		},
+		{
+			name:    "host of an existing service",
+			service: CloudflareTunnelService{Host: "app.other", Protocol: "http"},
+			want:    types.NamespacedName{Name: "app", Namespace: "other"},
+			wantOK:  true,
+		},
+		{
+			name:    "fully qualified host of an existing service",
+			service: CloudflareTunnelService{Host: "app.other.", Protocol: "http"},
+		},
+		{
+			name:    "host of a namespace that can't be read",
+			service: CloudflareTunnelService{Host: "app.forbidden", Protocol: "http"},
+			wantErr: true,
+		},
+		{
+			name:    "external host",
+			service: CloudflareTunnelService{Host: "example.com", Protocol: "https"},
+		},
+		{
+			name:    "fully qualified external host",
+			service: CloudflareTunnelService{Host: "example.com.", Protocol: "https"},
+		},
+		{
+			name:    "external host with subdomain",
+			service: CloudflareTunnelService{Host: "app.example.com", Protocol: "https"},
+		},
+		{
+			// cluster IPs aren't matched to their services and need a NetworkPolicy instead
+			name:    "cluster ip of an existing service",
+			service: CloudflareTunnelService{Host: "10.96.0.10", Protocol: "http"},
+		},
+		{
+			name:    "ipv6 address",
+			service: CloudflareTunnelService{Host: "[fd00::1]", Protocol: "http"},
+		},
+		{
+			name:    "status code",
+			service: CloudflareTunnelService{Protocol: ProtocolHTTPStatus, StatusCode: 404},
+		},
+		{
+			name:    "hello world",
+			service: CloudflareTunnelService{Protocol: ProtocolHelloWorld},
+		},
+		{
+			name:    "unix socket",
+			service: CloudflareTunnelService{Protocol: ProtocolUnix, Path: "/run/app.sock"},
+		},
+	}
+	c := forbiddenNamespaceReader{Reader: newTestClient(t, existing), namespace: "forbidden"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := tt.service.TargetService(context.Background(), c, "tunnels")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TargetService() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("TargetService() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// forbiddenNamespaceReader fails to read the objects of a namespace, like a reader without the permissions for it
+type forbiddenNamespaceReader struct {
+	client.Reader
+	namespace string
+}
+
+func (r forbiddenNamespaceReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if key.Namespace == r.namespace {
+		return apierrors.NewForbidden(corev1.Resource("services"), key.Name, errors.New("namespace is not readable"))
+	}
+	return r.Reader.Get(ctx, key, obj)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReferenceGrantSpec defines which tunnels may reference which resources in the namespace of the ReferenceGrant
+type ReferenceGrantSpec struct {
+	// From are the tunnels allowed to reference the resources in To
+	// +kubebuilder:validation:MinItems=1
+	From []ReferenceGrantFrom `json:"from"`
+	// To are the resources that may be referenced
+	// +kubebuilder:validation:MinItems=1
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// ReferenceGrantFrom describes the resources in another namespace allowed to reference resources of this namespace
+type ReferenceGrantFrom struct {
+	// +kubebuilder:validation:Enum=cloudflare-tunnel-operator.beezlabs.app
+	Group string `json:"group"`
+	// +kubebuilder:validation:Enum=CloudflareTunnel
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo describes the resources of this namespace that may be referenced
+type ReferenceGrantTo struct {
+	// Group is empty for the core API group
+	// +kubebuilder:validation:Enum=""
+	Group string `json:"group"`
+	// +kubebuilder:validation:Enum=Service
+	Kind string `json:"kind"`
+	// Name of the resource, every resource of the kind may be referenced when it is not set
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ReferenceGrant allows tunnels in other namespaces to route to the Services of its namespace, modelled on the
+// ReferenceGrant of the Gateway API
+type ReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ReferenceGrantList contains a list of ReferenceGrant
+type ReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ReferenceGrant{}, &ReferenceGrantList{})
+}
@@ -0,0 +1,962 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnel) DeepCopyInto(out *CloudflareTunnel) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnel.
+func (in *CloudflareTunnel) DeepCopy() *CloudflareTunnel {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareTunnel) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccess) DeepCopyInto(out *CloudflareTunnelAccess) {
+	*out = *in
+	if in.Policies != nil {
+		in, out := &in.Policies, &out.Policies
+		*out = make([]CloudflareTunnelAccessPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccess.
+func (in *CloudflareTunnelAccess) DeepCopy() *CloudflareTunnelAccess {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccessPolicy) DeepCopyInto(out *CloudflareTunnelAccessPolicy) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = new(CloudflareTunnelAccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = new(CloudflareTunnelAccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Require != nil {
+		in, out := &in.Require, &out.Require
+		*out = new(CloudflareTunnelAccessRules)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccessPolicy.
+func (in *CloudflareTunnelAccessPolicy) DeepCopy() *CloudflareTunnelAccessPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccessPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccessRules) DeepCopyInto(out *CloudflareTunnelAccessRules) {
+	*out = *in
+	if in.Emails != nil {
+		in, out := &in.Emails, &out.Emails
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EmailDomains != nil {
+		in, out := &in.EmailDomains, &out.EmailDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceTokens != nil {
+		in, out := &in.ServiceTokens, &out.ServiceTokens
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ServiceTokenRefs != nil {
+		in, out := &in.ServiceTokenRefs, &out.ServiceTokenRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPRanges != nil {
+		in, out := &in.IPRanges, &out.IPRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccessRules.
+func (in *CloudflareTunnelAccessRules) DeepCopy() *CloudflareTunnelAccessRules {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccessRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAccountSecretRef) DeepCopyInto(out *CloudflareTunnelAccountSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAccountSecretRef.
+func (in *CloudflareTunnelAccountSecretRef) DeepCopy() *CloudflareTunnelAccountSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAccountSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelAutoscaling) DeepCopyInto(out *CloudflareTunnelAutoscaling) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TargetCPUUtilizationPercentage != nil {
+		in, out := &in.TargetCPUUtilizationPercentage, &out.TargetCPUUtilizationPercentage
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]v2.MetricSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelAutoscaling.
+func (in *CloudflareTunnelAutoscaling) DeepCopy() *CloudflareTunnelAutoscaling {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelAutoscaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelConnections) DeepCopyInto(out *CloudflareTunnelConnections) {
+	*out = *in
+	in.Created.DeepCopyInto(&out.Created)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelConnections.
+func (in *CloudflareTunnelConnections) DeepCopy() *CloudflareTunnelConnections {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelConnections)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelContainer) DeepCopyInto(out *CloudflareTunnelContainer) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelContainer.
+func (in *CloudflareTunnelContainer) DeepCopy() *CloudflareTunnelContainer {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelContainer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelDNS) DeepCopyInto(out *CloudflareTunnelDNS) {
+	*out = *in
+	if in.Proxied != nil {
+		in, out := &in.Proxied, &out.Proxied
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelDNS.
+func (in *CloudflareTunnelDNS) DeepCopy() *CloudflareTunnelDNS {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelDNS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelHighAvailability) DeepCopyInto(out *CloudflareTunnelHighAvailability) {
+	*out = *in
+	if in.PodDisruptionBudget != nil {
+		in, out := &in.PodDisruptionBudget, &out.PodDisruptionBudget
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.SpreadPods != nil {
+		in, out := &in.SpreadPods, &out.SpreadPods
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelHighAvailability.
+func (in *CloudflareTunnelHighAvailability) DeepCopy() *CloudflareTunnelHighAvailability {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelHighAvailability)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelIPRule) DeepCopyInto(out *CloudflareTunnelIPRule) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelIPRule.
+func (in *CloudflareTunnelIPRule) DeepCopy() *CloudflareTunnelIPRule {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelIPRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelIngressRule) DeepCopyInto(out *CloudflareTunnelIngressRule) {
+	*out = *in
+	in.Service.DeepCopyInto(&out.Service)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelIngressRule.
+func (in *CloudflareTunnelIngressRule) DeepCopy() *CloudflareTunnelIngressRule {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelIngressRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelList) DeepCopyInto(out *CloudflareTunnelList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]CloudflareTunnel, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelList.
+func (in *CloudflareTunnelList) DeepCopy() *CloudflareTunnelList {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *CloudflareTunnelList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelMonitoring) DeepCopyInto(out *CloudflareTunnelMonitoring) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Relabelings != nil {
+		in, out := &in.Relabelings, &out.Relabelings
+		*out = make([]CloudflareTunnelRelabelConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelMonitoring.
+func (in *CloudflareTunnelMonitoring) DeepCopy() *CloudflareTunnelMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelOriginAccess) DeepCopyInto(out *CloudflareTunnelOriginAccess) {
+	*out = *in
+	if in.AudTag != nil {
+		in, out := &in.AudTag, &out.AudTag
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelOriginAccess.
+func (in *CloudflareTunnelOriginAccess) DeepCopy() *CloudflareTunnelOriginAccess {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelOriginAccess)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelOriginCA) DeepCopyInto(out *CloudflareTunnelOriginCA) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelOriginCA.
+func (in *CloudflareTunnelOriginCA) DeepCopy() *CloudflareTunnelOriginCA {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelOriginCA)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelOriginRequest) DeepCopyInto(out *CloudflareTunnelOriginRequest) {
+	*out = *in
+	if in.ConnectTimeout != nil {
+		in, out := &in.ConnectTimeout, &out.ConnectTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TLSTimeout != nil {
+		in, out := &in.TLSTimeout, &out.TLSTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TCPKeepAlive != nil {
+		in, out := &in.TCPKeepAlive, &out.TCPKeepAlive
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.NoHappyEyeballs != nil {
+		in, out := &in.NoHappyEyeballs, &out.NoHappyEyeballs
+		*out = new(bool)
+		**out = **in
+	}
+	if in.KeepAliveConnections != nil {
+		in, out := &in.KeepAliveConnections, &out.KeepAliveConnections
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KeepAliveTimeout != nil {
+		in, out := &in.KeepAliveTimeout, &out.KeepAliveTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.NoTLSVerify != nil {
+		in, out := &in.NoTLSVerify, &out.NoTLSVerify
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableChunkedEncoding != nil {
+		in, out := &in.DisableChunkedEncoding, &out.DisableChunkedEncoding
+		*out = new(bool)
+		**out = **in
+	}
+	if in.BastionMode != nil {
+		in, out := &in.BastionMode, &out.BastionMode
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ProxyPort != nil {
+		in, out := &in.ProxyPort, &out.ProxyPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IPRules != nil {
+		in, out := &in.IPRules, &out.IPRules
+		*out = make([]CloudflareTunnelIPRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HTTP2Origin != nil {
+		in, out := &in.HTTP2Origin, &out.HTTP2Origin
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(CloudflareTunnelOriginAccess)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelOriginRequest.
+func (in *CloudflareTunnelOriginRequest) DeepCopy() *CloudflareTunnelOriginRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelOriginRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelPodTemplate) DeepCopyInto(out *CloudflareTunnelPodTemplate) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]corev1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]corev1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AutomountServiceAccountToken != nil {
+		in, out := &in.AutomountServiceAccountToken, &out.AutomountServiceAccountToken
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelPodTemplate.
+func (in *CloudflareTunnelPodTemplate) DeepCopy() *CloudflareTunnelPodTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelPodTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelPrivateNetwork) DeepCopyInto(out *CloudflareTunnelPrivateNetwork) {
+	*out = *in
+	if in.CIDRs != nil {
+		in, out := &in.CIDRs, &out.CIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelPrivateNetwork.
+func (in *CloudflareTunnelPrivateNetwork) DeepCopy() *CloudflareTunnelPrivateNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelPrivateNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelProbe) DeepCopyInto(out *CloudflareTunnelProbe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelProbe.
+func (in *CloudflareTunnelProbe) DeepCopy() *CloudflareTunnelProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelProbes) DeepCopyInto(out *CloudflareTunnelProbes) {
+	*out = *in
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(CloudflareTunnelProbe)
+		**out = **in
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(CloudflareTunnelProbe)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelProbes.
+func (in *CloudflareTunnelProbes) DeepCopy() *CloudflareTunnelProbes {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelProbes)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelRef) DeepCopyInto(out *CloudflareTunnelRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelRef.
+func (in *CloudflareTunnelRef) DeepCopy() *CloudflareTunnelRef {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelRelabelConfig) DeepCopyInto(out *CloudflareTunnelRelabelConfig) {
+	*out = *in
+	if in.SourceLabels != nil {
+		in, out := &in.SourceLabels, &out.SourceLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelRelabelConfig.
+func (in *CloudflareTunnelRelabelConfig) DeepCopy() *CloudflareTunnelRelabelConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelRelabelConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelRoute) DeepCopyInto(out *CloudflareTunnelRoute) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelRoute.
+func (in *CloudflareTunnelRoute) DeepCopy() *CloudflareTunnelRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelService) DeepCopyInto(out *CloudflareTunnelService) {
+	*out = *in
+	if in.SocketVolume != nil {
+		in, out := &in.SocketVolume, &out.SocketVolume
+		*out = new(CloudflareTunnelSocketVolume)
+		**out = **in
+	}
+	if in.OriginRequest != nil {
+		in, out := &in.OriginRequest, &out.OriginRequest
+		*out = new(CloudflareTunnelOriginRequest)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelService.
+func (in *CloudflareTunnelService) DeepCopy() *CloudflareTunnelService {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelSocketVolume) DeepCopyInto(out *CloudflareTunnelSocketVolume) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelSocketVolume.
+func (in *CloudflareTunnelSocketVolume) DeepCopy() *CloudflareTunnelSocketVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelSocketVolume)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelSpec) DeepCopyInto(out *CloudflareTunnelSpec) {
+	*out = *in
+	if in.Ingress != nil {
+		in, out := &in.Ingress, &out.Ingress
+		*out = make([]CloudflareTunnelIngressRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(CloudflareTunnelDNS)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Container != nil {
+		in, out := &in.Container, &out.Container
+		*out = new(CloudflareTunnelContainer)
+		(*in).DeepCopyInto(*out)
+	}
+	out.AccountSecretRef = in.AccountSecretRef
+	if in.TunnelRef != nil {
+		in, out := &in.TunnelRef, &out.TunnelRef
+		*out = new(CloudflareTunnelRef)
+		**out = **in
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(CloudflareTunnelAutoscaling)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodTemplate != nil {
+		in, out := &in.PodTemplate, &out.PodTemplate
+		*out = new(CloudflareTunnelPodTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HighAvailability != nil {
+		in, out := &in.HighAvailability, &out.HighAvailability
+		*out = new(CloudflareTunnelHighAvailability)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(CloudflareTunnelMonitoring)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(CloudflareTunnelProbes)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OriginRequest != nil {
+		in, out := &in.OriginRequest, &out.OriginRequest
+		*out = new(CloudflareTunnelOriginRequest)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OriginCA != nil {
+		in, out := &in.OriginCA, &out.OriginCA
+		*out = new(CloudflareTunnelOriginCA)
+		**out = **in
+	}
+	if in.PrivateNetwork != nil {
+		in, out := &in.PrivateNetwork, &out.PrivateNetwork
+		*out = new(CloudflareTunnelPrivateNetwork)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(CloudflareTunnelAccess)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelSpec.
+func (in *CloudflareTunnelSpec) DeepCopy() *CloudflareTunnelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareTunnelStatus) DeepCopyInto(out *CloudflareTunnelStatus) {
+	*out = *in
+	if in.Connections != nil {
+		in, out := &in.Connections, &out.Connections
+		*out = make([]CloudflareTunnelConnections, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]CloudflareTunnelRoute, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareTunnelStatus.
+func (in *CloudflareTunnelStatus) DeepCopy() *CloudflareTunnelStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudflareTunnelStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrant) DeepCopyInto(out *ReferenceGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrant.
+func (in *ReferenceGrant) DeepCopy() *ReferenceGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReferenceGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantFrom) DeepCopyInto(out *ReferenceGrantFrom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantFrom.
+func (in *ReferenceGrantFrom) DeepCopy() *ReferenceGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantList) DeepCopyInto(out *ReferenceGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ReferenceGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantList.
+func (in *ReferenceGrantList) DeepCopy() *ReferenceGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReferenceGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantSpec) DeepCopyInto(out *ReferenceGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]ReferenceGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]ReferenceGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantSpec.
+func (in *ReferenceGrantSpec) DeepCopy() *ReferenceGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReferenceGrantTo) DeepCopyInto(out *ReferenceGrantTo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReferenceGrantTo.
+func (in *ReferenceGrantTo) DeepCopy() *ReferenceGrantTo {
+	if in == nil {
+		return nil
+	}
+	out := new(ReferenceGrantTo)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -54,6 +54,23 @@ helm repo add beezlabs https://charts.beezlabs.app/
 helm install my-cloudflare-tunnel-operator beezlabs/cloudflare-tunnel-operator --version 0.1.0
 ```
 
+## Upgrading
+
+The CRD of CloudflareTunnel is part of the templates, so that its conversion can point at the webhook of the release.
+Releases that installed it from the `crds` directory of an older chart left it without the metadata Helm expects of
+the resources it owns, and `helm upgrade` fails with `invalid ownership metadata`. Hand it over to the release once
+before upgrading:
+
+```sh
+kubectl annotate crd cloudflaretunnels.cloudflare-tunnel-operator.beezlabs.app \
+  meta.helm.sh/release-name=my-cloudflare-tunnel-operator \
+  meta.helm.sh/release-namespace=<namespace of the release>
+kubectl label crd cloudflaretunnels.cloudflare-tunnel-operator.beezlabs.app app.kubernetes.io/managed-by=Helm
+```
+
+The CRD carries the `helm.sh/resource-policy: keep` annotation, so `helm uninstall` leaves it and the
+CloudflareTunnels behind. Delete it by hand to remove them.
+
 ## Usage
 1. Figure out the service that you want to connect to. In the below example, the service looks as following
     ```yaml
@@ -91,7 +108,7 @@ helm install my-cloudflare-tunnel-operator beezlabs/cloudflare-tunnel-operator -
    NAMESPACE    NAME            AGE
    cloudflare   sample-tunnel   3d21h
    ```
-5. Access the URL that is set as domain
+5. Access the URL that is set as hostname
    ```bash
    https://example.sayakm.me
    ```
@@ -103,6 +120,16 @@ helm install my-cloudflare-tunnel-operator beezlabs/cloudflare-tunnel-operator -
 | image.repository          | string | `"ghcr.io/beezlabs-org/cloudflare-tunnel-operator"` | The image of the operator              |
 | image.pullPolicy          | string | `"IfNotPresent`                                     | The image pull policy for the operator |
 | image.tag                 | string | `"v0.1.0"`                                          | The image tag ofe the operator         |
+| webhook.enabled           | bool   | `false`                                             | Default and validate CloudflareTunnels with webhooks, the conversion webhook is always served |
+| webhook.failurePolicy     | string | `"Fail"`                                            | What the API server does when the webhook can't be reached |
+| webhook.certManager.enabled | bool | `false`                                             | Issue the serving certificate of the webhooks with cert-manager instead of a self-signed one generated by helm |
+| watchNamespaces           | list   | `[]`                                                | Namespaces the operator watches, all namespaces when empty |
+| rbac.namespaced           | bool   | `false`                                             | Create a Role and RoleBinding in each of the watchNamespaces instead of a ClusterRole |
+| crds.install              | bool   | `true`                                              | Install the templated CRD of CloudflareTunnel, only one release of a shared cluster should, use `--skip-crds` for the others |
+| proxyRole.create          | bool   | `true`                                              | Create the ClusterRole and ClusterRoleBinding reviewing tokens and access |
+| shardSelector             | string | `""`                                                | Label selector of the resources reconciled by this release, all of them when empty |
+| clusterID                 | string | `""`                                                | Identifies the cluster in the names of the tunnels when several clusters share an account |
+| tunnelNameTemplate        | string | `""`                                                | Go template of the names of the tunnels in Cloudflare, `<clusterID>-<namespace>-<name>` when empty |
 
 Current values file [here](https://github.com/beezlabs-org/cloudflare-tunnel-operator/blob/main/charts/values.yaml)
 
@@ -23,6 +23,14 @@ If release name contains chart name it will be used as a full name.
 {{- end }}
 {{- end }}
 
+{{/*
+Name of the cluster-scoped resources, which also contains the namespace of the release so that the releases sharing a
+cluster don't overwrite each other's resources.
+*/}}
+{{- define "chart.clusterScopedName" -}}
+{{- printf "%s-%s" (include "chart.fullname" .) .Release.Namespace | trunc 253 | trimSuffix "-" }}
+{{- end }}
+
 {{/*
 Create chart name and version as used by the chart label.
 */}}
@@ -60,3 +68,213 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Rules of the manager, rendered as a ClusterRole or as a Role in each watched namespace
+*/}}
+{{- define "chart.managerRules" -}}
+- apiGroups:
+    - ""
+  resources:
+    - configmaps
+    - secrets
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - ""
+  resources:
+    - services
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - monitoring.coreos.com
+  resources:
+    - podmonitors
+    - servicemonitors
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - apps
+  resources:
+    - deployments
+    - daemonsets
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - autoscaling
+  resources:
+    - horizontalpodautoscalers
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - policy
+  resources:
+    - poddisruptionbudgets
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - cloudflaretunnels
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - cloudflaretunnels/finalizers
+  verbs:
+    - update
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - cloudflaretunnels/status
+  verbs:
+    - get
+    - patch
+    - update
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - cloudflarevirtualnetworks
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - cloudflarevirtualnetworks/finalizers
+  verbs:
+    - update
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - cloudflarevirtualnetworks/status
+  verbs:
+    - get
+    - patch
+    - update
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - accessservicetokens
+  verbs:
+    - create
+    - delete
+    - get
+    - list
+    - patch
+    - update
+    - watch
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - accessservicetokens/finalizers
+  verbs:
+    - update
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - accessservicetokens/status
+  verbs:
+    - get
+    - patch
+    - update
+- apiGroups:
+    - cloudflare-tunnel-operator.beezlabs.app
+  resources:
+    - referencegrants
+  verbs:
+    - get
+    - list
+    - watch
+{{- end }}
+
+{{/*
+Serving certificate of the webhooks when it is not issued by cert-manager, as yaml with the base64 encoded ca, crt and
+key. The certificate of the installed release is reused, otherwise a new one is generated. It is kept in the values so
+that every template of the same render, e.g. the secret and the caBundles, gets the same certificate.
+*/}}
+{{- define "chart.webhookCert" -}}
+{{- if not .Values.webhook.generatedCert }}
+{{- $secretName := printf "%s-webhook-server-cert" (include "chart.fullname" .) }}
+{{- $data := dig "data" dict (lookup "v1" "Secret" .Release.Namespace $secretName) }}
+{{- if hasKey $data "ca.crt" }}
+{{- $_ := set .Values.webhook "generatedCert" (dict "ca" (index $data "ca.crt") "crt" (index $data "tls.crt") "key" (index $data "tls.key")) }}
+{{- else }}
+{{- $service := printf "%s-webhook" (include "chart.fullname" .) }}
+{{- $ca := genCA (printf "%s-ca" $service) 3650 }}
+{{- $dnsNames := list (printf "%s.%s.svc" $service .Release.Namespace) (printf "%s.%s.svc.cluster.local" $service .Release.Namespace) }}
+{{- $cert := genSignedCert $service nil $dnsNames 3650 $ca }}
+{{- $_ := set .Values.webhook "generatedCert" (dict "ca" ($ca.Cert | b64enc) "crt" ($cert.Cert | b64enc) "key" ($cert.Key | b64enc)) }}
+{{- end }}
+{{- end }}
+{{- toYaml .Values.webhook.generatedCert }}
+{{- end }}
+
+{{/*
+Client config of the webhooks of the API server, pointing at the service of the webhook server
+*/}}
+{{- define "chart.webhookClientConfig" -}}
+{{- $service := dict "name" (printf "%s-webhook" (include "chart.fullname" .context)) "namespace" .context.Release.Namespace "path" .path }}
+{{- if .context.Values.webhook.certManager.enabled }}
+{{- toYaml (dict "service" $service) }}
+{{- else }}
+{{- toYaml (dict "service" $service "caBundle" (include "chart.webhookCert" .context | fromYaml).ca) }}
+{{- end }}
+{{- end }}
+
+{{/*
+Annotations making cert-manager inject its CA into the caBundles of the webhooks
+*/}}
+{{- define "chart.webhookAnnotations" -}}
+{{- if .Values.webhook.certManager.enabled }}
+cert-manager.io/inject-ca-from: {{ .Release.Namespace }}/{{ include "chart.fullname" . }}-serving-cert
+{{- end }}
+{{- end }}
@@ -0,0 +1,345 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cfv1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/models"
+)
+
+// AccessServiceTokenReconciler reconciles a AccessServiceToken object
+type AccessServiceTokenReconciler struct {
+	Client        client.Client
+	Scheme        *runtime.Scheme
+	ShardSelector labels.Selector // only the resources matching it are reconciled, all of them when nil
+	logger        *logr.Logger
+}
+
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=accessservicetokens,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=accessservicetokens/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=accessservicetokens/finalizers,verbs=update
+
+func (r *AccessServiceTokenReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	lfc := log.FromContext(ctx)
+	r.logger = &lfc
+	lfc.Info("Reconciling...")
+
+	var serviceToken cfv1.AccessServiceToken
+	if err := r.Client.Get(ctx, req.NamespacedName, &serviceToken); err != nil {
+		// the resource is gone once the finalizer has been removed, nothing more to do
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	lfc.V(1).Info("Resource fetched")
+
+	// the resource may have been relabelled into another shard while a requeue was pending
+	if !inShard(r.ShardSelector, &serviceToken) {
+		lfc.V(1).Info("Resource belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	if !serviceToken.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizeServiceToken(ctx, &serviceToken)
+	}
+
+	accountToken, accountTag, err := fetchCredentials(ctx, r.Client, r.logger, serviceToken.Namespace, serviceToken.Spec.TokenSecretName, cfv1.TokenSecretTokenKey, cfv1.TokenSecretAccountIDKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	cf, err := newCloudflareAPI(r.logger, accountToken, accountTag)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the finalizer ensures that the tokens are revoked in the remote before the resource is gone
+	if !controllerutil.ContainsFinalizer(&serviceToken, constants.Finalizer) {
+		controllerutil.AddFinalizer(&serviceToken, constants.Finalizer)
+		if err := r.Client.Update(ctx, &serviceToken); err != nil {
+			lfc.Error(err, "could not add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	now := time.Now()
+	status := &serviceToken.Status
+
+	// the previous token is revoked once the overlap window is over
+	if status.PreviousTokenID != "" && (status.PreviousTokenDeleteAt == nil || !now.Before(status.PreviousTokenDeleteAt.Time)) {
+		if err := r.deleteServiceTokenRemote(ctx, cf, status.PreviousTokenID); err != nil {
+			return ctrl.Result{}, err
+		}
+		status.PreviousTokenID = ""
+		status.PreviousTokenDeleteAt = nil
+	}
+
+	rotate, err := r.needsRotation(ctx, cf, &serviceToken, now)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if rotate {
+		if err := r.rotateServiceToken(ctx, cf, &serviceToken, now); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.Client.Status().Update(ctx, &serviceToken); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// come back early if the rotation or the revocation of the previous token is due before the usual interval
+	requeueAfter := time.Minute * 5
+	if status.ExpiresAt != nil {
+		if untilRenewal := status.ExpiresAt.Add(-renewBefore(&serviceToken)).Sub(now); untilRenewal < requeueAfter {
+			requeueAfter = untilRenewal
+		}
+	}
+	if status.PreviousTokenDeleteAt != nil {
+		if untilDelete := status.PreviousTokenDeleteAt.Sub(now); untilDelete < requeueAfter {
+			requeueAfter = untilDelete
+		}
+	}
+	if requeueAfter < time.Second {
+		requeueAfter = time.Second
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AccessServiceTokenReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cfv1.AccessServiceToken{}, builder.WithPredicates(shardPredicate(r.ShardSelector))).
+		Complete(r)
+}
+
+func (r *AccessServiceTokenReconciler) finalizeServiceToken(ctx context.Context, serviceToken *cfv1.AccessServiceToken) error {
+	if !controllerutil.ContainsFinalizer(serviceToken, constants.Finalizer) {
+		return nil
+	}
+
+	accountToken, accountTag, err := fetchCredentials(ctx, r.Client, r.logger, serviceToken.Namespace, serviceToken.Spec.TokenSecretName, cfv1.TokenSecretTokenKey, cfv1.TokenSecretAccountIDKey)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		// without credentials nothing can be cleaned up, which must not block the deletion, e.g. of the namespace
+		r.logger.Info("credentials secret is gone, skipping the revocation of the service tokens")
+	} else {
+		cf, err := newCloudflareAPI(r.logger, accountToken, accountTag)
+		if err != nil {
+			return err
+		}
+		for _, tokenID := range []string{serviceToken.Status.TokenID, serviceToken.Status.PreviousTokenID} {
+			if err := r.deleteServiceTokenRemote(ctx, cf, tokenID); err != nil {
+				return err
+			}
+		}
+	}
+
+	controllerutil.RemoveFinalizer(serviceToken, constants.Finalizer)
+	if err := r.Client.Update(ctx, serviceToken); err != nil {
+		r.logger.Error(err, "could not remove finalizer")
+		return err
+	}
+	return nil
+}
+
+func (r *AccessServiceTokenReconciler) needsRotation(ctx context.Context, cf *cloudflare.API, serviceToken *cfv1.AccessServiceToken, now time.Time) (bool, error) {
+	status := &serviceToken.Status
+	if status.TokenID == "" {
+		r.logger.Info("Service token doesn't exist. Creating...")
+		return true, nil
+	}
+
+	// there is no endpoint to fetch a single token, so the list is searched
+	tokens, _, err := cf.AccessServiceTokens(ctx, cf.AccountID)
+	if err != nil {
+		r.logger.Error(err, "could not fetch service token list")
+		return false, err
+	}
+	found := false
+	for _, token := range tokens {
+		if token.ID == status.TokenID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		// the token was revoked outside of the operator, it cannot be kept as the previous token
+		r.logger.Info("Service token doesn't exist in the remote. Recreating...")
+		status.TokenID = ""
+		status.ClientID = ""
+		status.ExpiresAt = nil
+		return true, nil
+	}
+
+	// the client secret is only returned on creation, so a lost secret can only be recovered by a rotation
+	var secret corev1.Secret
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: serviceTokenSecretName(serviceToken), Namespace: serviceToken.Namespace}, &secret); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		r.logger.Info("Service token secret doesn't exist. Rotating...")
+		return true, nil
+	}
+	if string(secret.Data[constants.ServiceTokenClientIDKey]) != status.ClientID || len(secret.Data[constants.ServiceTokenClientSecretKey]) == 0 {
+		r.logger.Info("Service token secret is out of date. Rotating...")
+		return true, nil
+	}
+
+	if status.ExpiresAt != nil && !now.Before(status.ExpiresAt.Add(-renewBefore(serviceToken))) {
+		r.logger.Info("Service token is about to expire. Rotating...")
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *AccessServiceTokenReconciler) rotateServiceToken(ctx context.Context, cf *cloudflare.API, serviceToken *cfv1.AccessServiceToken, now time.Time) error {
+	remoteName := serviceToken.Spec.RemoteName
+	if remoteName == "" {
+		remoteName = serviceToken.Namespace + "-" + serviceToken.Name
+	}
+	created, err := cf.CreateAccessServiceToken(ctx, cf.AccountID, remoteName)
+	if err != nil {
+		r.logger.Error(err, "could not create service token")
+		return err
+	}
+
+	if err := r.createSecret(ctx, serviceToken, created.ClientID, created.ClientSecret); err != nil {
+		// nobody would ever be able to use the new token, so don't leave it behind
+		if deleteErr := r.deleteServiceTokenRemote(ctx, cf, created.ID); deleteErr != nil {
+			r.logger.Error(deleteErr, "could not delete unused service token")
+		}
+		return err
+	}
+
+	status := &serviceToken.Status
+	if status.TokenID != "" {
+		// only a single previous token is kept valid, an older one is revoked right away
+		if status.PreviousTokenID != "" {
+			if err := r.deleteServiceTokenRemote(ctx, cf, status.PreviousTokenID); err != nil {
+				return err
+			}
+		}
+		status.PreviousTokenID = status.TokenID
+		status.PreviousTokenDeleteAt = &metav1.Time{Time: now.Add(overlap(serviceToken))}
+	}
+	status.TokenID = created.ID
+	status.ClientID = created.ClientID
+	status.ExpiresAt = nil
+	if created.ExpiresAt != nil {
+		status.ExpiresAt = &metav1.Time{Time: *created.ExpiresAt}
+	}
+
+	// the new token is only known to the status, so it is saved right away instead of at the end of the reconcile
+	if err := r.Client.Status().Update(ctx, serviceToken); err != nil {
+		r.logger.Error(err, "could not save the rotated service token")
+		if deleteErr := r.deleteServiceTokenRemote(ctx, cf, created.ID); deleteErr != nil {
+			r.logger.Error(deleteErr, "could not delete unsaved service token")
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *AccessServiceTokenReconciler) createSecret(ctx context.Context, serviceToken *cfv1.AccessServiceToken, clientID string, clientSecret string) error {
+	var secretFetch corev1.Secret
+	secretCreate := models.ServiceTokenSecret(models.ServiceTokenSecretModel{
+		Name:         serviceToken.Name,
+		SecretName:   serviceTokenSecretName(serviceToken),
+		Namespace:    serviceToken.Namespace,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	}).GetSecret()
+
+	// the secret needs to have an owner reference back to the controller
+	if err := ctrl.SetControllerReference(serviceToken, secretCreate, r.Scheme); err != nil {
+		r.logger.Error(err, "could not create controller reference in secret")
+		return err
+	}
+	r.logger.V(1).Info("Owner Reference for Secret created")
+
+	// try to get an existing secret with the given name
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: secretCreate.Name, Namespace: secretCreate.Namespace}, &secretFetch); err != nil {
+		if errors.IsNotFound(err) {
+			// error due to secret not being present, so, create one
+			r.logger.Info("creating secret...")
+			if err := r.Client.Create(ctx, secretCreate); err != nil {
+				r.logger.Error(err, "could not create secret in cluster")
+				return err
+			}
+		} else {
+			return err
+		}
+	} else {
+		// secret exists, so update it with the new credentials
+		if err := r.Client.Update(ctx, secretCreate); err != nil {
+			r.logger.Error(err, "could not update secret")
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *AccessServiceTokenReconciler) deleteServiceTokenRemote(ctx context.Context, cf *cloudflare.API, tokenID string) error {
+	if tokenID == "" {
+		return nil
+	}
+	r.logger.Info("deleting service token " + tokenID)
+	if _, err := cf.DeleteAccessServiceToken(ctx, cf.AccountID, tokenID); err != nil && !isCloudflareNotFound(err) {
+		r.logger.Error(err, "could not delete service token")
+		return err
+	}
+	return nil
+}
+
+func serviceTokenSecretName(serviceToken *cfv1.AccessServiceToken) string {
+	if serviceToken.Spec.SecretName != "" {
+		return serviceToken.Spec.SecretName
+	}
+	return serviceToken.Name + "-" + constants.ServiceTokenSecretSuffix
+}
+
+func renewBefore(serviceToken *cfv1.AccessServiceToken) time.Duration {
+	if serviceToken.Spec.RenewBefore != nil {
+		return serviceToken.Spec.RenewBefore.Duration
+	}
+	return time.Hour * 720
+}
+
+func overlap(serviceToken *cfv1.AccessServiceToken) time.Duration {
+	if serviceToken.Spec.Overlap != nil {
+		return serviceToken.Spec.Overlap.Duration
+	}
+	return time.Hour * 24
+}
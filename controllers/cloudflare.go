@@ -0,0 +1,109 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// fetchCredentials reads the account token and the account id from the given keys of the given secret
+func fetchCredentials(ctx context.Context, c client.Client, logger *logr.Logger, namespace string, secretName string, tokenKey string, accountIDKey string) (string, string, error) {
+	// check if a secret name is mentioned in the resource or not
+	// secretName is the name of the secret resource that contains the account id and account token
+	if len(secretName) == 0 {
+		err := fmt.Errorf("CredentialSecretName key does not exist")
+		logger.Error(err, "CredentialSecretName not found")
+		return "", "", err
+	}
+
+	var secret corev1.Secret
+	// try to get a secret with the given name
+	if err := c.Get(ctx, types.NamespacedName{
+		Name:      secretName,
+		Namespace: namespace,
+	}, &secret); err != nil {
+		if errors.IsNotFound(err) {
+			// write a log only if the secret was not found and not for other errors
+			logger.Error(err, "could not find secret with name "+secretName)
+		}
+		return "", "", err
+	}
+	logger.V(1).Info("Secret fetched")
+
+	// secret found, decode the token
+	encodedToken, okCred := secret.Data[tokenKey]
+	encodedAccountID, okAccount := secret.Data[accountIDKey]
+
+	if !okCred {
+		err := fmt.Errorf("invalid key")
+		logger.Error(err, "key "+tokenKey+" not found")
+		return "", "", err
+	}
+
+	if !okAccount {
+		err := fmt.Errorf("invalid key")
+		logger.Error(err, "key "+accountIDKey+" not found")
+		return "", "", err
+	}
+	logger.V(1).Info("Secret decoded")
+
+	return string(encodedToken), string(encodedAccountID), nil // everything good
+}
+
+// newCloudflareAPI creates an instance of the cloudflare sdk scoped to the given account
+func newCloudflareAPI(logger *logr.Logger, accountToken string, accountTag string) (*cloudflare.API, error) {
+	// the requests are made through a transport recording the metrics of the API usage
+	httpClient := &http.Client{Transport: metricsTransport{next: http.DefaultTransport}}
+	cf, err := cloudflare.NewWithAPIToken(accountToken, cloudflare.HTTPClient(httpClient)) // create new instance of cloudflare sdk
+	if err != nil {
+		logger.Error(err, "could not create cloudflare instance")
+		return nil, err
+	}
+	logger.V(1).Info("Cloudflare instance successfully created")
+
+	cf.AccountID = accountTag
+	return cf, nil
+}
+
+// deleteTunnelRoute deletes the route for the network in the given virtual network
+// cloudflare-go drops the virtual network of TunnelRoutesDeleteParams, which would delete the route of the
+// default virtual network instead, so the request is made directly
+func deleteTunnelRoute(cf *cloudflare.API, network string, virtualNetworkID string) error {
+	uri := fmt.Sprintf("/%s/%s/teamnet/routes/network/%s", cloudflare.AccountRouteRoot, cf.AccountID, url.PathEscape(network))
+	if virtualNetworkID != "" {
+		uri += "?" + url.Values{"virtual_network_id": []string{virtualNetworkID}}.Encode()
+	}
+	_, err := cf.Raw(http.MethodDelete, uri, nil)
+	return err
+}
+
+// isCloudflareNotFound checks if the error is due to the resource not being present in the remote
+func isCloudflareNotFound(err error) bool {
+	var notFoundError *cloudflare.NotFoundError
+	return goerrors.As(err, &notFoundError)
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/cloudflare-go"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cfv1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+func (r *CloudflareTunnelReconciler) createAccessApplication(ctx context.Context, cloudflareTunnel *cfv1beta1.CloudflareTunnel) error {
+	access := r.TunEx.TunSpec.Access
+	if access == nil {
+		// the access block might have been removed from a tunnel that had it earlier
+		return r.deleteAccessApplication(ctx)
+	}
+
+	// the webhook rejects this already, but it might be disabled
+	hostnames := r.TunEx.TunSpec.Hostnames()
+	if len(hostnames) != 1 {
+		err := fmt.Errorf("the Access application needs the ingress rules to have exactly one hostname, found %d", len(hostnames))
+		r.logger.Error(err, "could not create Access application")
+		return err
+	}
+	domain := hostnames[0]
+
+	cf := r.TunEx.CloudflareAPI
+	name := access.Name
+	if name == "" {
+		name = r.TunEx.Name
+	}
+	application := cloudflare.AccessApplication{
+		Name:            name,
+		Domain:          domain,
+		Type:            cloudflare.SelfHosted,
+		SessionDuration: access.SessionDuration,
+	}
+
+	// check if the application in the status still exists in the remote
+	exists := false
+	if r.TunEx.AccessApplicationID != "" {
+		if _, err := cf.AccessApplication(ctx, cf.AccountID, r.TunEx.AccessApplicationID); err != nil {
+			if !isCloudflareNotFound(err) {
+				r.logger.Error(err, "could not fetch Access application")
+				return err
+			}
+		} else {
+			exists = true
+		}
+	}
+
+	if exists {
+		r.logger.V(1).Info("Access application exists, updating")
+		application.ID = r.TunEx.AccessApplicationID
+		updated, err := cf.UpdateAccessApplication(ctx, cf.AccountID, application)
+		if err != nil {
+			r.logger.Error(err, "could not update Access application")
+			return err
+		}
+		application = updated
+	} else {
+		r.logger.Info("Access application doesn't exist. Creating...")
+		created, err := cf.CreateAccessApplication(ctx, cf.AccountID, application)
+		if err != nil {
+			r.logger.Error(err, "could not create Access application")
+			return err
+		}
+		application = created
+	}
+	r.TunEx.AccessApplicationID = application.ID
+	r.TunEx.AccessApplicationAUD = application.AUD
+	if !exists {
+		// the application is only ever looked up by the id in the status, an application of the account in front of
+		// the same domain might have been created by anyone else
+		if err := r.recordRemoteIDs(ctx, cloudflareTunnel); err != nil {
+			return err
+		}
+	}
+
+	if err := r.fetchAccessTeamName(ctx); err != nil {
+		return err
+	}
+
+	return r.createAccessPolicies(ctx, access.Policies)
+}
+
+// fetchAccessTeamName reads the team name from the spec, falling back to the Access organization of the account
+func (r *CloudflareTunnelReconciler) fetchAccessTeamName(ctx context.Context) error {
+	if teamName := r.TunEx.TunSpec.Access.TeamName; teamName != "" {
+		r.TunEx.AccessTeamName = teamName
+		return nil
+	}
+	cf := r.TunEx.CloudflareAPI
+	organization, _, err := cf.AccessOrganization(ctx, cf.AccountID)
+	if err != nil {
+		r.logger.Error(err, "could not fetch Access organization")
+		return err
+	}
+	// the auth domain is of the form <team name>.cloudflareaccess.com
+	r.TunEx.AccessTeamName = strings.TrimSuffix(organization.AuthDomain, constants.AccessAuthDomainSuffix)
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) createAccessPolicies(ctx context.Context, policies []cfv1beta1.CloudflareTunnelAccessPolicy) error {
+	cf := r.TunEx.CloudflareAPI
+	existingPolicies, _, err := cf.AccessPolicies(ctx, cf.AccountID, r.TunEx.AccessApplicationID, cloudflare.PaginationOptions{})
+	if err != nil {
+		r.logger.Error(err, "could not fetch Access policies")
+		return err
+	}
+	// policies are matched by name since that is the only stable identifier in the spec
+	existingByName := map[string]cloudflare.AccessPolicy{}
+	for _, policy := range existingPolicies {
+		existingByName[policy.Name] = policy
+	}
+
+	desiredNames := map[string]bool{}
+	for i, policy := range policies {
+		desiredNames[policy.Name] = true
+		accessPolicy := cloudflare.AccessPolicy{
+			Name:       policy.Name,
+			Decision:   policy.Decision,
+			Precedence: i + 1,
+		}
+		if accessPolicy.Include, err = r.accessRules(ctx, policy.Include); err != nil {
+			return err
+		}
+		if accessPolicy.Exclude, err = r.accessRules(ctx, policy.Exclude); err != nil {
+			return err
+		}
+		if accessPolicy.Require, err = r.accessRules(ctx, policy.Require); err != nil {
+			return err
+		}
+		if existing, ok := existingByName[policy.Name]; ok {
+			accessPolicy.ID = existing.ID
+			if _, err := cf.UpdateAccessPolicy(ctx, cf.AccountID, r.TunEx.AccessApplicationID, accessPolicy); err != nil {
+				r.logger.Error(err, "could not update Access policy "+policy.Name)
+				return err
+			}
+		} else {
+			r.logger.Info("creating Access policy " + policy.Name)
+			if _, err := cf.CreateAccessPolicy(ctx, cf.AccountID, r.TunEx.AccessApplicationID, accessPolicy); err != nil {
+				r.logger.Error(err, "could not create Access policy "+policy.Name)
+				return err
+			}
+		}
+	}
+
+	for _, policy := range existingPolicies {
+		if desiredNames[policy.Name] {
+			continue
+		}
+		r.logger.Info("deleting Access policy " + policy.Name)
+		if err := cf.DeleteAccessPolicy(ctx, cf.AccountID, r.TunEx.AccessApplicationID, policy.ID); err != nil {
+			r.logger.Error(err, "could not delete Access policy "+policy.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) deleteAccessApplication(ctx context.Context) error {
+	if r.TunEx.AccessApplicationID == "" {
+		return nil
+	}
+	cf := r.TunEx.CloudflareAPI
+	r.logger.Info("deleting Access application...")
+	// the policies of an application are deleted along with it
+	if err := cf.DeleteAccessApplication(ctx, cf.AccountID, r.TunEx.AccessApplicationID); err != nil && !isCloudflareNotFound(err) {
+		r.logger.Error(err, "could not delete Access application")
+		return err
+	}
+	r.TunEx.AccessApplicationID = ""
+	r.TunEx.AccessApplicationAUD = ""
+	return nil
+}
+
+// accessRules converts the rules of the spec to the rule objects of the Access API
+func (r *CloudflareTunnelReconciler) accessRules(ctx context.Context, rules *cfv1beta1.CloudflareTunnelAccessRules) ([]interface{}, error) {
+	accessRules := []interface{}{}
+	if rules == nil {
+		return accessRules, nil
+	}
+	serviceTokens := rules.ServiceTokens
+	for _, serviceTokenRef := range rules.ServiceTokenRefs {
+		var serviceToken cfv1.AccessServiceToken
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: serviceTokenRef, Namespace: r.TunEx.Namespace}, &serviceToken); err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Error(err, "could not find service token with name "+serviceTokenRef)
+			}
+			return nil, err
+		}
+		if serviceToken.Status.TokenID == "" {
+			err := fmt.Errorf("service token not ready")
+			r.logger.Error(err, "service token "+serviceTokenRef+" has not been created yet")
+			return nil, err
+		}
+		// the previous token is still in use by clients during the overlap window of a rotation
+		serviceTokens = append(serviceTokens, serviceToken.Status.TokenID)
+		if serviceToken.Status.PreviousTokenID != "" {
+			serviceTokens = append(serviceTokens, serviceToken.Status.PreviousTokenID)
+		}
+	}
+
+	if rules.Everyone {
+		accessRules = append(accessRules, cloudflare.AccessGroupEveryone{})
+	}
+	for _, email := range rules.Emails {
+		rule := cloudflare.AccessGroupEmail{}
+		rule.Email.Email = email
+		accessRules = append(accessRules, rule)
+	}
+	for _, domain := range rules.EmailDomains {
+		rule := cloudflare.AccessGroupEmailDomain{}
+		rule.EmailDomain.Domain = domain
+		accessRules = append(accessRules, rule)
+	}
+	for _, group := range rules.Groups {
+		rule := cloudflare.AccessGroupAccessGroup{}
+		rule.Group.ID = group
+		accessRules = append(accessRules, rule)
+	}
+	for _, serviceToken := range serviceTokens {
+		rule := cloudflare.AccessGroupServiceToken{}
+		rule.ServiceToken.ID = serviceToken
+		accessRules = append(accessRules, rule)
+	}
+	if rules.AnyValidServiceToken {
+		accessRules = append(accessRules, cloudflare.AccessGroupAnyValidServiceToken{})
+	}
+	for _, ipRange := range rules.IPRanges {
+		rule := cloudflare.AccessGroupIP{}
+		rule.IP.IP = ipRange
+		accessRules = append(accessRules, rule)
+	}
+	return accessRules, nil
+}
+
+// tunnelsForAccessServiceToken enqueues the tunnels whose Access policies reference the AccessServiceToken, so that a
+// rotated token is allowed by the policies as soon as it is written to its secret
+func (r *CloudflareTunnelReconciler) tunnelsForAccessServiceToken(object client.Object) []reconcile.Request {
+	serviceToken, ok := object.(*cfv1.AccessServiceToken)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+	var cloudflareTunnels cfv1beta1.CloudflareTunnelList
+	if err := r.Client.List(ctx, &cloudflareTunnels, client.InNamespace(serviceToken.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, "could not list CloudflareTunnels for AccessServiceToken "+serviceToken.Name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, cloudflareTunnel := range cloudflareTunnels.Items {
+		if !inShard(r.ShardSelector, &cloudflareTunnel) || !referencesServiceToken(cloudflareTunnel.Spec.Access, serviceToken.Name) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+			Name:      cloudflareTunnel.Name,
+			Namespace: cloudflareTunnel.Namespace,
+		}})
+	}
+	return requests
+}
+
+// referencesServiceToken checks if any rule of the Access policies references the AccessServiceToken by its name
+func referencesServiceToken(access *cfv1beta1.CloudflareTunnelAccess, name string) bool {
+	if access == nil {
+		return false
+	}
+	for _, policy := range access.Policies {
+		for _, rules := range []*cfv1beta1.CloudflareTunnelAccessRules{policy.Include, policy.Exclude, policy.Require} {
+			if rules == nil {
+				continue
+			}
+			for _, serviceTokenRef := range rules.ServiceTokenRefs {
+				if serviceTokenRef == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
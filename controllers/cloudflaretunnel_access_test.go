@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cfv1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+)
+
+func TestTunnelsForAccessServiceToken(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	tunnel := func(namespace string, name string, shard string, policies ...cfv1beta1.CloudflareTunnelAccessPolicy) client.Object {
+		cloudflareTunnel := &cfv1beta1.CloudflareTunnel{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"shard": shard}},
+		}
+		if len(policies) != 0 {
+			cloudflareTunnel.Spec.Access = &cfv1beta1.CloudflareTunnelAccess{Policies: policies}
+		}
+		return cloudflareTunnel
+	}
+	serviceTokenRefs := func(names ...string) *cfv1beta1.CloudflareTunnelAccessRules {
+		return &cfv1beta1.CloudflareTunnelAccessRules{ServiceTokenRefs: names}
+	}
+	objects := []client.Object{
+		tunnel("tunnels", "include", "a", cfv1beta1.CloudflareTunnelAccessPolicy{Name: "ci", Include: serviceTokenRefs("other", "ci")}),
+		tunnel("tunnels", "require", "a", cfv1beta1.CloudflareTunnelAccessPolicy{Name: "ci", Require: serviceTokenRefs("ci")}),
+		tunnel("tunnels", "other-token", "a", cfv1beta1.CloudflareTunnelAccessPolicy{Name: "ci", Include: serviceTokenRefs("other")}),
+		tunnel("tunnels", "without-access", "a"),
+		tunnel("tunnels", "other-shard", "b", cfv1beta1.CloudflareTunnelAccessPolicy{Name: "ci", Exclude: serviceTokenRefs("ci")}),
+		tunnel("other", "other-namespace", "a", cfv1beta1.CloudflareTunnelAccessPolicy{Name: "ci", Include: serviceTokenRefs("ci")}),
+	}
+	r := &CloudflareTunnelReconciler{
+		Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		ShardSelector: labels.SelectorFromSet(labels.Set{"shard": "a"}),
+	}
+
+	serviceToken := &cfv1.AccessServiceToken{ObjectMeta: metav1.ObjectMeta{Name: "ci", Namespace: "tunnels"}}
+	got := r.tunnelsForAccessServiceToken(serviceToken)
+	want := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Name: "include", Namespace: "tunnels"}},
+		{NamespacedName: types.NamespacedName{Name: "require", Namespace: "tunnels"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tunnelsForAccessServiceToken() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudflare/cloudflare-go"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+// adoptTunnel looks up the existing tunnel referenced by the spec, which has to belong to the account of the resource
+func (r *CloudflareTunnelReconciler) adoptTunnel(ctx context.Context, ref *cfv1beta1.CloudflareTunnelRef) (cloudflare.Tunnel, error) {
+	cf := r.TunEx.CloudflareAPI
+	accountResourceContainer := cloudflare.AccountIdentifier(cf.AccountID)
+
+	var tunnel cloudflare.Tunnel
+	if ref.ID != "" {
+		// the tunnels are looked up under the account, so a tunnel of another account is not found
+		found, err := cf.Tunnel(ctx, accountResourceContainer, ref.ID)
+		if err != nil {
+			if isCloudflareNotFound(err) {
+				err = fmt.Errorf("tunnel %s does not exist in account %s", ref.ID, cf.AccountID)
+			}
+			r.logger.Error(err, "could not fetch the tunnel to adopt")
+			return cloudflare.Tunnel{}, err
+		}
+		tunnel = found
+	} else {
+		falsePointer := false // needed as the function below only accepts a *bool
+		tunnels, err := cf.Tunnels(ctx, accountResourceContainer, cloudflare.TunnelListParams{
+			Name:      ref.Name,
+			IsDeleted: &falsePointer,
+		})
+		if err != nil {
+			r.logger.Error(err, "could not fetch tunnel list")
+			return cloudflare.Tunnel{}, err
+		}
+		if len(tunnels) != 1 {
+			err := fmt.Errorf("%d tunnels exist with the name %s in account %s", len(tunnels), ref.Name, cf.AccountID)
+			r.logger.Error(err, "the tunnel to adopt has to be referenced by its id")
+			return cloudflare.Tunnel{}, err
+		}
+		tunnel = tunnels[0]
+	}
+	if tunnel.DeletedAt != nil {
+		err := fmt.Errorf("tunnel %s has been deleted", tunnel.ID)
+		r.logger.Error(err, "could not adopt the tunnel")
+		return cloudflare.Tunnel{}, err
+	}
+
+	if err := r.checkTunnelUnclaimed(ctx, tunnel.ID); err != nil {
+		return cloudflare.Tunnel{}, err
+	}
+
+	if !r.TunEx.Adopted || r.TunEx.TunnelID != tunnel.ID {
+		r.logger.Info("adopting tunnel " + tunnel.Name + " (" + tunnel.ID + ")")
+	}
+	// the name of an adopted tunnel is left as it is
+	r.TunEx.Adopted = true
+	r.TunEx.RemoteName = tunnel.Name
+	return tunnel, nil
+}
+
+// checkTunnelUnclaimed makes sure no other resource manages the tunnel already, the webhook rejects such references
+// but might be disabled
+func (r *CloudflareTunnelReconciler) checkTunnelUnclaimed(ctx context.Context, tunnelID string) error {
+	var cloudflareTunnels cfv1beta1.CloudflareTunnelList
+	if err := r.Client.List(ctx, &cloudflareTunnels); err != nil {
+		r.logger.Error(err, "could not list CloudflareTunnels")
+		return err
+	}
+	for _, other := range cloudflareTunnels.Items {
+		if other.Namespace == r.TunEx.Namespace && other.Name == r.TunEx.Name {
+			continue
+		}
+		if other.Status.TunnelID == tunnelID {
+			err := fmt.Errorf("tunnel %s is already managed by CloudflareTunnel %s/%s", tunnelID, other.Namespace, other.Name)
+			r.logger.Error(err, "could not take over the tunnel")
+			return err
+		}
+	}
+	return nil
+}
+
+// deletesAdoptedTunnel checks if the adopted tunnel is to be deleted along with the resource
+func (r *CloudflareTunnelReconciler) deletesAdoptedTunnel() bool {
+	ref := r.TunEx.TunSpec.TunnelRef
+	return ref != nil && ref.DeletionPolicy == cfv1beta1.DeletionPolicyDelete
+}
+
+// deleteWorkload deletes the Deployment or DaemonSet running the connectors and reports whether it is gone. The
+// workload is deleted in the foreground, so that it only disappears once its pods have stopped
+func (r *CloudflareTunnelReconciler) deleteWorkload(ctx context.Context) (bool, error) {
+	name := types.NamespacedName{Name: r.TunEx.Name + "-" + constants.ResourceSuffix, Namespace: r.TunEx.Namespace}
+	stopped := true
+	for _, object := range []client.Object{&appsv1.Deployment{}, &appsv1.DaemonSet{}} {
+		if err := r.Client.Get(ctx, name, object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			r.logger.Error(err, "could not fetch the workload")
+			return false, err
+		}
+		stopped = false
+		if !object.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		r.logger.Info("deleting workload...")
+		if err := r.Client.Delete(ctx, object, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil && !errors.IsNotFound(err) {
+			r.logger.Error(err, "could not delete the workload")
+			return false, err
+		}
+	}
+	return stopped, nil
+}
+
+// deleteTunnelRemote deletes the tunnel along with its DNS records and routes
+func (r *CloudflareTunnelReconciler) deleteTunnelRemote(ctx context.Context) error {
+	if r.TunEx.TunnelID == "" {
+		return nil
+	}
+	if err := r.deleteDNSCNAMEs(ctx); err != nil {
+		return err
+	}
+	cf := r.TunEx.CloudflareAPI
+	for _, route := range r.TunEx.Routes {
+		r.logger.Info("deleting tunnel route " + route.Network)
+		if err := deleteTunnelRoute(cf, route.Network, route.VirtualNetworkID); err != nil && !isCloudflareNotFound(err) {
+			r.logger.Error(err, "could not delete tunnel route")
+			return err
+		}
+	}
+	r.TunEx.Routes = nil
+
+	accountResourceContainer := cloudflare.AccountIdentifier(cf.AccountID)
+	r.logger.Info("deleting tunnel...")
+	// a tunnel with active connections cannot be deleted, which includes the ones left behind by connectors that
+	// have already stopped
+	if err := cf.CleanupTunnelConnections(ctx, accountResourceContainer, r.TunEx.TunnelID); err != nil && !isCloudflareNotFound(err) {
+		r.logger.Error(err, "could not clean up tunnel connections")
+		return err
+	}
+	if err := cf.DeleteTunnel(ctx, accountResourceContainer, r.TunEx.TunnelID); err != nil && !isCloudflareNotFound(err) {
+		r.logger.Error(err, "could not delete tunnel")
+		return err
+	}
+	r.TunEx.TunnelID = ""
+	return nil
+}
+
+// deleteDNSCNAMEs deletes the records of the hostnames of the ingress rules that still point to the tunnel
+func (r *CloudflareTunnelReconciler) deleteDNSCNAMEs(ctx context.Context) error {
+	cf := r.TunEx.CloudflareAPI
+	zoneID, err := cf.ZoneIDByName(r.TunEx.TunSpec.Zone)
+	if err != nil {
+		r.logger.Error(err, "could not fetch zone id")
+		return err
+	}
+	for _, hostname := range r.TunEx.TunSpec.Hostnames() {
+		dnsRecords, err := cf.DNSRecords(ctx, zoneID, cloudflare.DNSRecord{
+			Type:    "CNAME",
+			Name:    hostname,
+			Content: r.TunEx.TunnelID + constants.CNAMESuffix,
+		})
+		if err != nil {
+			r.logger.Error(err, "could not fetch dns list")
+			return err
+		}
+		for _, dnsRecord := range dnsRecords {
+			r.logger.Info("deleting DNS record " + dnsRecord.Name)
+			if err := cf.DeleteDNSRecord(ctx, zoneID, dnsRecord.ID); err != nil && !isCloudflareNotFound(err) {
+				r.logger.Error(err, "could not delete DNS record")
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+func TestDeleteWorkload(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	name := "tunnel-" + constants.ResourceSuffix
+	now := metav1.Now()
+	tests := []struct {
+		name   string
+		object client.Object
+		// whether the workload was already gone
+		wantStopped bool
+	}{
+		{
+			name:        "no workload",
+			wantStopped: true,
+		},
+		{
+			name:   "deployment",
+			object: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "tunnels"}},
+		},
+		{
+			name:   "daemonset",
+			object: &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "tunnels"}},
+		},
+		{
+			name: "deployment waiting for its pods",
+			object: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "tunnels",
+				DeletionTimestamp: &now,
+				Finalizers:        []string{metav1.FinalizerDeleteDependents},
+			}},
+		},
+		{
+			name:        "workload of another tunnel",
+			object:      &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "other-" + constants.ResourceSuffix, Namespace: "tunnels"}},
+			wantStopped: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logr.Discard()
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			if tt.object != nil {
+				builder = builder.WithObjects(tt.object)
+			}
+			r := &CloudflareTunnelReconciler{
+				Client: builder.Build(),
+				TunEx:  &TunnelExpanded{Name: "tunnel", Namespace: "tunnels"},
+				logger: &logger,
+			}
+			stopped, err := r.deleteWorkload(context.Background())
+			if err != nil {
+				t.Fatalf("deleteWorkload() error = %v", err)
+			}
+			if stopped != tt.wantStopped {
+				t.Errorf("deleteWorkload() = %v, want %v", stopped, tt.wantStopped)
+			}
+			if tt.object != nil && !tt.wantStopped && tt.object.GetDeletionTimestamp() == nil {
+				err := r.Client.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "tunnels"}, tt.object)
+				if err == nil {
+					t.Errorf("deleteWorkload() did not delete the %T", tt.object)
+				}
+			}
+		})
+	}
+}
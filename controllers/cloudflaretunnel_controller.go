@@ -20,49 +20,88 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	goerrors "errors"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/cloudflare/cloudflare-go"
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	cfv1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
 	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
 	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/models"
 )
 
 // CloudflareTunnelReconciler reconciles a CloudflareTunnel object
 type CloudflareTunnelReconciler struct {
-	Client client.Client
-	TunEx  *TunnelExpanded
-	Scheme *runtime.Scheme
-	logger *logr.Logger
+	Client             client.Client
+	APIReader          client.Reader // reads without the cache, to look up services of namespaces it doesn't watch
+	TunEx              *TunnelExpanded
+	Scheme             *runtime.Scheme
+	ShardSelector      labels.Selector    // only the resources matching it are reconciled, all of them when nil
+	TunnelNameTemplate *template.Template // names the remote tunnels, DefaultTunnelNameTemplate when nil
+	ClusterID          string             // passed to the tunnel name template
+	logger             *logr.Logger
 }
 
 type TunnelExpanded struct {
-	TunSpec       cfv1.CloudflareTunnelSpec
-	CloudflareAPI *cloudflare.API
-	AccountToken  string // contains the token for the cloudflare account
-	AccountTag    string // contains the user id/tag for the cloudflare account
-	Name          string // name of the CRD as well as the tunnel
-	Namespace     string // namespace of the CRD
-	TunnelID      string // tunnel ID as generated by the remote
-	TunnelSecret  string // the secret that is generated by us to create and then connect to the tunnel
+	TunSpec              cfv1beta1.CloudflareTunnelSpec
+	CloudflareAPI        *cloudflare.API
+	AccountToken         string                            // contains the token for the cloudflare account
+	AccountTag           string                            // contains the user id/tag for the cloudflare account
+	Name                 string                            // name of the CRD as well as the tunnel
+	Namespace            string                            // namespace of the CRD
+	TunnelID             string                            // tunnel ID as generated by the remote
+	RemoteName           string                            // name of the tunnel in the remote
+	Adopted              bool                              // whether the tunnel was adopted instead of created by the operator
+	TunnelToken          string                            // the token, as returned by the remote, used by cloudflared to connect to the tunnel
+	Routes               []cfv1beta1.CloudflareTunnelRoute // private network routes registered for the tunnel
+	AccessApplicationID  string                            // id of the Access application in front of the hostname
+	AccessApplicationAUD string                            // audience tag of the Access application
+	AccessTeamName       string                            // team name used to validate the Access token at the origin
+	RefErrors            []string                          // services of the ingress rules that may not be referenced
+}
+
+// workloadReadiness is the number of connectors of the Deployment or DaemonSet that are ready
+type workloadReadiness struct {
+	Desired  int32 // replicas of the deployment or nodes of the daemonset
+	Ready    int32
+	UpToDate bool // whether the latest spec has been observed by the workload controller
 }
 
 //+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflaretunnels,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflaretunnels/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflaretunnels/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflarevirtualnetworks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=accessservicetokens,verbs=get;list;watch
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors;podmonitors,verbs=get;list;watch;create;update;patch;delete
 
 func (r *CloudflareTunnelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	lfc := log.FromContext(ctx)
@@ -70,149 +109,284 @@ func (r *CloudflareTunnelReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	lfc.Info("Reconciling...")
 	namespacedName := req.NamespacedName
 
-	var cloudflareTunnel cfv1.CloudflareTunnel
+	var cloudflareTunnel cfv1beta1.CloudflareTunnel
 	if err := r.Client.Get(ctx, namespacedName, &cloudflareTunnel); err != nil {
+		if errors.IsNotFound(err) {
+			// the resource is gone once the finalizer has been removed, nothing more to do
+			deleteTunnelMetrics(namespacedName)
+			return ctrl.Result{}, nil
+		}
 		lfc.Error(err, "could not fetch CloudflareTunnel")
 		return ctrl.Result{}, err
 	}
 	lfc.V(1).Info("Resource fetched")
 
+	// the resource may have been relabelled into another shard while a requeue was pending
+	if !inShard(r.ShardSelector, &cloudflareTunnel) {
+		lfc.V(1).Info("Resource belongs to another shard, skipping")
+		// the connectors are reported by the shard the tunnel belongs to now
+		deleteTunnelMetrics(namespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	// the defaults are applied to a copy so that resources created without the webhook behave the same
+	tunSpec := *cloudflareTunnel.Spec.DeepCopy()
+	tunSpec.Default(cloudflareTunnel.Namespace)
+
 	r.TunEx = &TunnelExpanded{
-		TunSpec:   cloudflareTunnel.Spec,
-		Name:      cloudflareTunnel.Name,
-		Namespace: cloudflareTunnel.Namespace,
-		TunnelID:  cloudflareTunnel.Status.TunnelID,
+		TunSpec:              tunSpec,
+		Name:                 cloudflareTunnel.Name,
+		Namespace:            cloudflareTunnel.Namespace,
+		TunnelID:             cloudflareTunnel.Status.TunnelID,
+		RemoteName:           cloudflareTunnel.Status.RemoteName,
+		Adopted:              cloudflareTunnel.Status.Adopted,
+		Routes:               cloudflareTunnel.Status.Routes,
+		AccessApplicationID:  cloudflareTunnel.Status.AccessApplicationID,
+		AccessApplicationAUD: cloudflareTunnel.Status.AccessApplicationAUD,
+	}
+
+	if !cloudflareTunnel.DeletionTimestamp.IsZero() {
+		return r.finalizeTunnel(ctx, &cloudflareTunnel)
 	}
 
+	timer := newPhaseTimer("cloudflaretunnel")
+	defer timer.stop()
+
+	timer.next("credentials")
 	if err := r.fetchDecodeSecret(ctx); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if err := r.createTunnelRemote(ctx); err != nil {
+	cf, err := newCloudflareAPI(r.logger, r.TunEx.AccountToken, r.TunEx.AccountTag)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.TunEx.CloudflareAPI = cf
+
+	// the finalizer is added before anything is changed in the remote, so that nothing is left behind on deletion
+	if !controllerutil.ContainsFinalizer(&cloudflareTunnel, constants.Finalizer) {
+		controllerutil.AddFinalizer(&cloudflareTunnel, constants.Finalizer)
+		if err := r.Client.Update(ctx, &cloudflareTunnel); err != nil {
+			lfc.Error(err, "could not add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	timer.next("tunnel")
+	if err := r.createTunnelRemote(ctx, &cloudflareTunnel); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.createTunnelRoutes(ctx); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// this concludes checking the remote tunnel config
+	timer.next("secret")
 	secretCreate, err := r.createSecret(ctx, cloudflareTunnel)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// now we have to check the deployment status and reconcile
-	url, err := r.getTargetURL(ctx)
+	timer.next("services")
+	urls := make([]string, len(r.TunEx.TunSpec.Ingress))
+	for i := range r.TunEx.TunSpec.Ingress {
+		urls[i], err = r.getTargetURL(ctx, &r.TunEx.TunSpec.Ingress[i].Service)
+		if goerrors.Is(err, errRefNotPermitted) {
+			// the rule answers with a 404 until a ReferenceGrant allows the service, which triggers a reconcile on its
+			// own, so that revoking a grant cuts off the service without keeping the previous config around
+			urls[i] = cfv1beta1.ProtocolHTTPStatus + ":" + strconv.Itoa(http.StatusNotFound)
+			r.TunEx.RefErrors = append(r.TunEx.RefErrors, err.Error())
+			continue
+		}
+		if err != nil {
+			lfc.Error(err, "could not generate URL for ingress rule "+strconv.Itoa(i))
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.validateOriginCA(ctx); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the Access application needs to exist before the config, which validates its tokens at the origin
+	timer.next("access")
+	if err = r.createAccessApplication(ctx, &cloudflareTunnel); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	timer.next("configmap")
+	configMapCreate, err := r.createConfigMap(ctx, cloudflareTunnel, urls)
 	if err != nil {
-		lfc.Error(err, "could not generate URL")
 		return ctrl.Result{}, err
 	}
 
-	configMapCreate, err := r.createConfigMap(ctx, cloudflareTunnel, url)
+	timer.next("deployment")
+	readiness, err := r.createWorkload(ctx, cloudflareTunnel, secretCreate, configMapCreate)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if _, err = r.createDeployment(ctx, cloudflareTunnel, secretCreate, configMapCreate); err != nil {
+	if err = r.createHorizontalPodAutoscaler(ctx, cloudflareTunnel); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err = r.createPodDisruptionBudget(ctx, cloudflareTunnel); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	timer.next("monitoring")
+	if err = r.createMonitoring(ctx, cloudflareTunnel); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// finally we need to check if a CNAME exists for the given domain and create if not
-	if err = r.createDNSCNAME(ctx); err != nil {
+	// finally we need to check if a CNAME exists for the hostnames and create if not
+	timer.next("dns")
+	if err = r.createDNSCNAMEs(ctx); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// update the status of the custom resource
-	if err := r.updateStatus(ctx, &cloudflareTunnel); err != nil {
+	timer.next("status")
+	if err := r.updateStatus(ctx, &cloudflareTunnel, readiness); err != nil {
 		return ctrl.Result{}, err
 	}
 	if err := r.Client.Status().Update(ctx, &cloudflareTunnel); err != nil {
 		return ctrl.Result{}, err
 	}
+	// the workload is not watched, so check back sooner while the connectors are coming up
+	if !meta.IsStatusConditionTrue(cloudflareTunnel.Status.Conditions, constants.ConditionReady) {
+		return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	}
 	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *CloudflareTunnelReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&cfv1.CloudflareTunnel{}).
+		For(&cfv1beta1.CloudflareTunnel{}, builder.WithPredicates(shardPredicate(r.ShardSelector))).
+		Watches(&source.Kind{Type: &cfv1beta1.ReferenceGrant{}}, handler.EnqueueRequestsFromMapFunc(r.tunnelsForReferenceGrant)).
+		Watches(&source.Kind{Type: &cfv1.AccessServiceToken{}}, handler.EnqueueRequestsFromMapFunc(r.tunnelsForAccessServiceToken)).
 		//Owns(&appsv1.Deployment{}).
 		Complete(r)
 }
 
-func (r *CloudflareTunnelReconciler) fetchDecodeSecret(ctx context.Context) error {
-	// check if a secret name is mentioned in the resource or not
-	// TokenSecretName is the name of the secret resource that contains the account id and account token
-	if len(r.TunEx.TunSpec.TokenSecretName) == 0 {
-		err := fmt.Errorf("CredentialSecretName key does not exist")
-		r.logger.Error(err, "CredentialSecretName not found")
-		return err
+func (r *CloudflareTunnelReconciler) finalizeTunnel(ctx context.Context, cloudflareTunnel *cfv1beta1.CloudflareTunnel) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(cloudflareTunnel, constants.Finalizer) {
+		return ctrl.Result{}, nil
 	}
 
-	var secret corev1.Secret
-	// try to get a secret with the given name
-	if err := r.Client.Get(ctx, types.NamespacedName{
-		Name:      r.TunEx.TunSpec.TokenSecretName,
-		Namespace: r.TunEx.Namespace,
-	}, &secret); err != nil {
-		if errors.IsNotFound(err) {
-			// write a log only if the secret was not found and not for other errors
-			r.logger.Error(err, "could not find secret with name "+r.TunEx.TunSpec.TokenSecretName)
+	if err := r.fetchDecodeSecret(ctx); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
 		}
-		return err
-	}
-	r.logger.V(1).Info("Secret fetched")
+		// without credentials nothing can be cleaned up, which must not block the deletion, e.g. of the namespace
+		r.logger.Info("credentials secret is gone, skipping the cleanup of remote resources")
+	} else {
+		cf, err := newCloudflareAPI(r.logger, r.TunEx.AccountToken, r.TunEx.AccountTag)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.TunEx.CloudflareAPI = cf
 
-	// secret found, decode the token
-	encodedToken, okCred := secret.Data["token"]
-	encodedAccountID, okAccount := secret.Data["accountID"]
+		if err := r.deleteAccessApplication(ctx); err != nil {
+			return ctrl.Result{}, err
+		}
 
-	if !okCred {
-		err := fmt.Errorf("invalid key")
-		r.logger.Error(err, "key credentials not found")
-		return err
+		// a tunnel adopted with the Retain policy is left as it is, along with its DNS records and routes
+		if !r.TunEx.Adopted || r.deletesAdoptedTunnel() {
+			// the connectors would reconnect right away and keep the tunnel from being deleted, so the workload is
+			// stopped first instead of leaving it to the garbage collection once the finalizer is removed
+			stopped, err := r.deleteWorkload(ctx)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !stopped {
+				r.logger.Info("waiting for the connectors to stop before deleting the tunnel")
+				return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+			}
+			if err := r.deleteTunnelRemote(ctx); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 	}
 
-	if !okAccount {
-		err := fmt.Errorf("invalid key")
-		r.logger.Error(err, "key accountID not found")
-		return err
+	controllerutil.RemoveFinalizer(cloudflareTunnel, constants.Finalizer)
+	if err := r.Client.Update(ctx, cloudflareTunnel); err != nil {
+		r.logger.Error(err, "could not remove finalizer")
+		return ctrl.Result{}, err
 	}
-	r.logger.V(1).Info("Secret decoded")
+	return ctrl.Result{}, nil
+}
 
-	r.TunEx.AccountTag = string(encodedAccountID)
-	r.TunEx.AccountToken = string(encodedToken)
+func (r *CloudflareTunnelReconciler) fetchDecodeSecret(ctx context.Context) error {
+	// AccountSecretRef references the secret resource that contains the account id and account token
+	secretRef := r.TunEx.TunSpec.AccountSecretRef
+	accountToken, accountTag, err := fetchCredentials(ctx, r.Client, r.logger, r.TunEx.Namespace, secretRef.Name, secretRef.TokenKey, secretRef.AccountIDKey)
+	if err != nil {
+		return err
+	}
+	r.TunEx.AccountTag = accountTag
+	r.TunEx.AccountToken = accountToken
 	return nil // everything good
 }
 
-func (r *CloudflareTunnelReconciler) createTunnelRemote(ctx context.Context) error {
-	cf, err := cloudflare.NewWithAPIToken(r.TunEx.AccountToken) // create new instance of cloudflare sdk
-	r.TunEx.CloudflareAPI = cf
+func (r *CloudflareTunnelReconciler) createTunnelRemote(ctx context.Context, cloudflareTunnel *cfv1beta1.CloudflareTunnel) error {
+	cf := r.TunEx.CloudflareAPI
+	accountResourceContainer := cloudflare.AccountIdentifier(cf.AccountID)
+
+	var tunnel cloudflare.Tunnel
+	var err error
+	if ref := r.TunEx.TunSpec.TunnelRef; ref != nil {
+		tunnel, err = r.adoptTunnel(ctx, ref)
+	} else {
+		tunnel, err = r.findOrCreateTunnel(ctx, cloudflareTunnel)
+	}
 	if err != nil {
-		r.logger.Error(err, "could not create cloudflare instance")
 		return err
 	}
-	r.logger.V(1).Info("Cloudflare instance successfully created")
+	r.TunEx.TunnelID = tunnel.ID // assign the tunnelID from the created or adopted tunnel
 
-	cf.AccountID = r.TunEx.AccountTag
+	tunnelToken, err := cf.TunnelToken(ctx, accountResourceContainer, tunnel.ID)
+	if err != nil {
+		r.logger.Error(err, "could not fetch tunnel token")
+		return err
+	}
+	r.TunEx.TunnelToken = tunnelToken
+	return nil
+}
+
+// findOrCreateTunnel looks up the tunnel created for the resource, creating it if it doesn't exist yet
+func (r *CloudflareTunnelReconciler) findOrCreateTunnel(ctx context.Context, cloudflareTunnel *cfv1beta1.CloudflareTunnel) (cloudflare.Tunnel, error) {
+	cf := r.TunEx.CloudflareAPI
+
+	remoteName, err := r.remoteTunnelName()
+	if err != nil {
+		return cloudflare.Tunnel{}, err
+	}
 
 	falsePointer := false // needed as the function below only accepts a *bool
 
 	// first, we are checking if tunnels with the given name exists in the remote or not
 	// if they exist, we will be getting one or more of them, since cloudflare allows duplicate named tunnels
-	// if 2 or more exists, we check if the current CRD status already has the TunnelID or not
-	// if it has, we check if the returned tunnels has one with the same connector id and use it
-	// else, we cannot accurately figure out which one of them to use and error out
+	// if the CRD status already has the TunnelID, the tunnel is looked up by it alone and keeps whatever name it has,
+	// the name template only applies to tunnels that are yet to be created
+	// else, if 2 or more exist we cannot accurately figure out which one of them to use and error out
 	tunnelListParams := cloudflare.TunnelListParams{
-		Name:      r.TunEx.Name,
+		Name:      remoteName,
 		IsDeleted: &falsePointer,
 	}
 	accountResourceContainer := cloudflare.AccountIdentifier(cf.AccountID)
 	// check if tunnelID already existed as part of the resource Status
 	if r.TunEx.TunnelID != "" {
+		tunnelListParams.Name = ""
 		tunnelListParams.UUID = r.TunEx.TunnelID
 	}
 	tunnels, err := cf.Tunnels(ctx, accountResourceContainer, tunnelListParams)
 	if err != nil {
 		r.logger.Error(err, "could not fetch tunnel list")
-		return err
+		return cloudflare.Tunnel{}, err
 	}
 	r.logger.V(1).Info("Existing tunnels fetched")
 
@@ -220,73 +394,205 @@ func (r *CloudflareTunnelReconciler) createTunnelRemote(ctx context.Context) err
 
 	if len(tunnels) >= 2 {
 		err := fmt.Errorf("multiple tunnels exist")
-		r.logger.Error(err, "2 or more tunnels already exists with the given name. Unable to choose between one of them")
-		return err
+		r.logger.Error(err, "2 or more tunnels already exists with the name "+remoteName+". Unable to choose between one of them")
+		return cloudflare.Tunnel{}, err
 	} else if len(tunnels) == 1 {
-		// a single tunnel found with the same name, so we use that
+		// a single tunnel found with the same name or id, so we use that
 		r.logger.Info("Tunnel already exists. Reconciling...")
 		tunnel = tunnels[0]
+		// a tunnel found by its name might belong to another resource whose name renders the same
+		if r.TunEx.TunnelID == "" {
+			if err := r.checkTunnelUnclaimed(ctx, tunnel.ID); err != nil {
+				return cloudflare.Tunnel{}, err
+			}
+			// the id of a tunnel the operator creates is recorded right away, so a tunnel only found by its name was
+			// created by someone else and is retained like one adopted through the tunnelRef
+			r.logger.Info("adopting tunnel " + tunnel.Name + " (" + tunnel.ID + ") found by its name")
+			r.TunEx.Adopted = true
+		}
+		// tunnels created before the names were qualified are migrated by recording the name they already have
+		remoteName = tunnel.Name
 	} else {
 		r.logger.Info("Tunnel doesn't exist. Creating...")
 		tunnelSecret, err := generateTunnelSecret() // generate a random secret to be used as the tunnel secret
 		if err != nil {
 			r.logger.Error(err, "could not generate tunnel secret")
-			return err
+			return cloudflare.Tunnel{}, err
 		}
 		r.logger.V(1).Info("Cloudflare Tunnel secret generated")
 
 		tunnelParams := cloudflare.TunnelCreateParams{
-			Name:   r.TunEx.Name, // name of the tunnel is the same as the name of the CRD
+			Name:   remoteName,
 			Secret: tunnelSecret, // use the randomly generated secret
 		}
 
 		tunnel, err = cf.CreateTunnel(ctx, accountResourceContainer, tunnelParams)
 		if err != nil {
 			r.logger.Error(err, "could not create the tunnel")
-			return err
+			return cloudflare.Tunnel{}, err
+		}
+		r.TunEx.TunnelID = tunnel.ID
+		r.TunEx.RemoteName = remoteName
+		r.TunEx.Adopted = false
+		if err := r.recordRemoteIDs(ctx, cloudflareTunnel); err != nil {
+			return cloudflare.Tunnel{}, err
 		}
 	}
-	r.TunEx.TunnelID = tunnel.ID // assign the tunnelID from the created tunnel
+	r.TunEx.RemoteName = remoteName
+	return tunnel, nil
+}
 
-	tunnelToken, err := cf.TunnelToken(ctx, accountResourceContainer, tunnel.ID)
+func (r *CloudflareTunnelReconciler) createTunnelRoutes(ctx context.Context) error {
+	// the routes are only registered when private network routing is enabled
+	desiredNetworks := map[string]bool{}
+	privateNetwork := r.TunEx.TunSpec.PrivateNetwork
+	if privateNetwork != nil && privateNetwork.Enabled {
+		for _, cidr := range privateNetwork.CIDRs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				r.logger.Error(err, "invalid CIDR "+cidr)
+				return err
+			}
+			// the remote always reports the network in its canonical form
+			desiredNetworks[network.String()] = true
+		}
+	}
+
+	if len(desiredNetworks) == 0 && len(r.TunEx.Routes) == 0 {
+		return nil
+	}
+
+	virtualNetworkID, err := r.getVirtualNetworkID(ctx)
 	if err != nil {
-		r.logger.Error(err, "could not fetch tunnel token")
 		return err
 	}
-	tunnelTokenDecodedBytes, err := base64.StdEncoding.DecodeString(tunnelToken)
+
+	accountResourceContainer := cloudflare.AccountIdentifier(r.TunEx.CloudflareAPI.AccountID)
+	falsePointer := false // needed as the struct below only accepts a *bool
+	existingRoutes, err := r.TunEx.CloudflareAPI.ListTunnelRoutes(ctx, accountResourceContainer, cloudflare.TunnelRoutesListParams{
+		TunnelID:  r.TunEx.TunnelID,
+		IsDeleted: &falsePointer,
+	})
 	if err != nil {
-		r.logger.Error(err, "could not decode tunnel token")
+		r.logger.Error(err, "could not fetch tunnel routes")
 		return err
 	}
-	r.TunEx.TunnelSecret = string(tunnelTokenDecodedBytes)
+	existing := map[cfv1beta1.CloudflareTunnelRoute]bool{}
+	for _, route := range existingRoutes {
+		existing[cfv1beta1.CloudflareTunnelRoute{Network: route.Network, VirtualNetworkID: route.VirtualNetworkID}] = true
+	}
+	// without a virtual network, the remote places the routes in the default virtual network
+	// so routes in any virtual network are matched
+	findExisting := func(network string) (cfv1beta1.CloudflareTunnelRoute, bool) {
+		for _, route := range existingRoutes {
+			if route.Network == network && (virtualNetworkID == "" || route.VirtualNetworkID == virtualNetworkID) {
+				return cfv1beta1.CloudflareTunnelRoute{Network: route.Network, VirtualNetworkID: route.VirtualNetworkID}, true
+			}
+		}
+		return cfv1beta1.CloudflareTunnelRoute{}, false
+	}
+
+	var routes []cfv1beta1.CloudflareTunnelRoute
+	desired := map[cfv1beta1.CloudflareTunnelRoute]bool{}
+	for network := range desiredNetworks {
+		route, found := findExisting(network)
+		if !found {
+			r.logger.Info("creating tunnel route " + network)
+			created, err := r.TunEx.CloudflareAPI.CreateTunnelRoute(ctx, accountResourceContainer, cloudflare.TunnelRoutesCreateParams{
+				Network:          network,
+				TunnelID:         r.TunEx.TunnelID,
+				Comment:          constants.ManagedComment,
+				VirtualNetworkID: virtualNetworkID,
+			})
+			if err != nil {
+				r.logger.Error(err, "could not create tunnel route")
+				return err
+			}
+			route = cfv1beta1.CloudflareTunnelRoute{Network: network, VirtualNetworkID: created.VirtualNetworkID}
+		}
+		desired[route] = true
+		routes = append(routes, route)
+	}
+
+	// routes registered earlier by us but no longer desired are removed from the remote
+	for _, route := range r.TunEx.Routes {
+		if desired[route] || !existing[route] {
+			continue
+		}
+		r.logger.Info("deleting tunnel route " + route.Network)
+		if err := deleteTunnelRoute(r.TunEx.CloudflareAPI, route.Network, route.VirtualNetworkID); err != nil {
+			r.logger.Error(err, "could not delete tunnel route")
+			return err
+		}
+	}
+
+	// keep the status stable across reconciles
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Network < routes[j].Network })
+	r.TunEx.Routes = routes
 	return nil
 }
 
-func (r *CloudflareTunnelReconciler) createDNSCNAME(ctx context.Context) error {
+func (r *CloudflareTunnelReconciler) getVirtualNetworkID(ctx context.Context) (string, error) {
+	privateNetwork := r.TunEx.TunSpec.PrivateNetwork
+	if privateNetwork == nil || privateNetwork.VirtualNetworkRef == "" {
+		return "", nil
+	}
+	var virtualNetwork cfv1.CloudflareVirtualNetwork
+	if err := r.Client.Get(ctx, types.NamespacedName{
+		Name:      privateNetwork.VirtualNetworkRef,
+		Namespace: r.TunEx.Namespace,
+	}, &virtualNetwork); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Error(err, "could not find virtual network with name "+privateNetwork.VirtualNetworkRef)
+		}
+		return "", err
+	}
+	if virtualNetwork.Status.VirtualNetworkID == "" {
+		err := fmt.Errorf("virtual network not ready")
+		r.logger.Error(err, "virtual network "+privateNetwork.VirtualNetworkRef+" has not been created yet")
+		return "", err
+	}
+	return virtualNetwork.Status.VirtualNetworkID, nil
+}
+
+func (r *CloudflareTunnelReconciler) createDNSCNAMEs(ctx context.Context) error {
 	zoneID, err := r.TunEx.CloudflareAPI.ZoneIDByName(r.TunEx.TunSpec.Zone)
 	if err != nil {
 		r.logger.Error(err, "could not fetch zone id")
 		return err
 	}
+	for _, rule := range r.TunEx.TunSpec.Ingress {
+		// the catch-all rule only gets the requests of records pointing to the tunnel by other means
+		if rule.Hostname == "" {
+			continue
+		}
+		if err := r.createDNSCNAME(ctx, zoneID, rule.Hostname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) createDNSCNAME(ctx context.Context, zoneID string, hostname string) error {
 	dnsRecords, err := r.TunEx.CloudflareAPI.DNSRecords(ctx, zoneID, cloudflare.DNSRecord{
 		Type: "CNAME",
-		Name: r.TunEx.TunSpec.Domain,
+		Name: hostname,
 	})
 	if err != nil {
 		r.logger.Error(err, "could not fetch dns list")
 		return err
 	}
-	truePointer := true // needed as the struct below only accepts a *bool
+	dns := r.TunEx.TunSpec.DNS
 	dnsRecord := cloudflare.DNSRecord{
 		Type:    "CNAME",
-		Name:    r.TunEx.TunSpec.Domain,
+		Name:    hostname,
 		Content: r.TunEx.TunnelID + constants.CNAMESuffix,
-		TTL:     0,
-		Proxied: &truePointer,
+		TTL:     dns.TTL,
+		Proxied: dns.Proxied,
 	}
 	if len(dnsRecords) >= 2 {
 		err := fmt.Errorf("multiple DNS records exist")
-		r.logger.Error(err, "2 or more DNS CNAME records already exists for the given name. Unable to choose between one of them")
+		r.logger.Error(err, "2 or more DNS CNAME records already exists for "+hostname+". Unable to choose between one of them")
 		return err
 	}
 	if len(dnsRecords) == 1 {
@@ -306,17 +612,19 @@ func (r *CloudflareTunnelReconciler) createDNSCNAME(ctx context.Context) error {
 	return nil
 }
 
-func (r *CloudflareTunnelReconciler) createSecret(ctx context.Context, cloudflareTunnel cfv1.CloudflareTunnel) (*corev1.Secret, error) {
+func (r *CloudflareTunnelReconciler) createSecret(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel) (*corev1.Secret, error) {
 	// now first we create the secret containing the creds to the tunnel
 	// this is fully contained in the fetched tunnel secret including the tunnel id and account tag
 	var secretFetch corev1.Secret
 	secretCreate, err := models.Secret(models.SecretModel{
-		Name:        r.TunEx.Name,
-		Namespace:   r.TunEx.Namespace,
-		TunnelToken: r.TunEx.TunnelSecret,
-		TunnelID:    r.TunEx.TunnelID,
+		Name:            r.TunEx.Name,
+		Namespace:       r.TunEx.Namespace,
+		CredentialsType: r.TunEx.TunSpec.CredentialsType,
+		TunnelToken:     r.TunEx.TunnelToken,
+		TunnelID:        r.TunEx.TunnelID,
 	}).GetSecret()
 	if err != nil {
+		r.logger.Error(err, "could not generate secret")
 		return nil, err
 	}
 
@@ -349,15 +657,40 @@ func (r *CloudflareTunnelReconciler) createSecret(ctx context.Context, cloudflar
 	return secretCreate, nil
 }
 
-func (r *CloudflareTunnelReconciler) createConfigMap(ctx context.Context, cloudflareTunnel cfv1.CloudflareTunnel, url string) (*corev1.ConfigMap, error) {
+func (r *CloudflareTunnelReconciler) createConfigMap(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel, urls []string) (*corev1.ConfigMap, error) {
 	// now first we create the configMap containing the configuration to the tunnel
 	var configMapFetch corev1.ConfigMap
+
+	// a mounted origin CA becomes the default caPool of the tunnel unless one is explicitly set
+	originRequest := r.TunEx.TunSpec.OriginRequest
+	if r.TunEx.TunSpec.OriginCA != nil {
+		if originRequest == nil {
+			originRequest = &cfv1beta1.CloudflareTunnelOriginRequest{}
+		} else {
+			originRequest = originRequest.DeepCopy()
+		}
+		if originRequest.CAPool == "" {
+			originRequest.CAPool = constants.OriginCADirectory + "/" + constants.OriginCAFile
+		}
+	}
+
+	ingress := make([]models.IngressRule, len(r.TunEx.TunSpec.Ingress))
+	for i, rule := range r.TunEx.TunSpec.Ingress {
+		ingress[i] = models.IngressRule{
+			Hostname:      rule.Hostname,
+			Service:       urls[i],
+			OriginRequest: r.serviceOriginRequest(rule),
+		}
+	}
+
 	configMapCreate, err := models.ConfigMap(models.ConfigMapModel{
-		Name:      r.TunEx.Name,
-		Namespace: r.TunEx.Namespace,
-		Service:   url,
-		TunnelID:  r.TunEx.TunnelID,
-		Domain:    r.TunEx.TunSpec.Domain,
+		Name:            r.TunEx.Name,
+		Namespace:       r.TunEx.Namespace,
+		Ingress:         ingress,
+		TunnelID:        r.TunEx.TunnelID,
+		CredentialsType: r.TunEx.TunSpec.CredentialsType,
+		WarpRouting:     r.TunEx.TunSpec.PrivateNetwork != nil && r.TunEx.TunSpec.PrivateNetwork.Enabled,
+		OriginRequest:   originRequest,
 	}).GetConfigMap()
 	if err != nil {
 		return nil, err
@@ -392,34 +725,122 @@ func (r *CloudflareTunnelReconciler) createConfigMap(ctx context.Context, cloudf
 	return configMapCreate, nil
 }
 
-func (r *CloudflareTunnelReconciler) createDeployment(ctx context.Context, cloudflareTunnel cfv1.CloudflareTunnel, secret *corev1.Secret, configMap *corev1.ConfigMap) (*appsv1.Deployment, error) {
-	// now first we create the configMap containing the configuration to the tunnel
-	var deploymentFetch appsv1.Deployment
-
-	tunnelDeploymentModel := models.DeploymentModel{
-		Name:      r.TunEx.Name,
-		Namespace: r.TunEx.Namespace,
-		Replicas:  r.TunEx.TunSpec.Replicas,
-		TunnelID:  r.TunEx.TunnelID,
-		Secret:    secret,
-		ConfigMap: configMap,
+// serviceOriginRequest returns the originRequest of the ingress rule
+// when the tunnel is protected by the managed Access application, cloudflared is made to validate the Access token on
+// every rule so that no origin can be reached by bypassing Access, unless the validation is explicitly configured
+func (r *CloudflareTunnelReconciler) serviceOriginRequest(rule cfv1beta1.CloudflareTunnelIngressRule) *cfv1beta1.CloudflareTunnelOriginRequest {
+	originRequest := rule.Service.OriginRequest
+	if r.TunEx.AccessApplicationAUD == "" || (originRequest != nil && originRequest.Access != nil) {
+		return originRequest
+	}
+	if originRequest == nil {
+		originRequest = &cfv1beta1.CloudflareTunnelOriginRequest{}
+	} else {
+		originRequest = originRequest.DeepCopy()
 	}
+	originRequest.Access = &cfv1beta1.CloudflareTunnelOriginAccess{
+		Required: true,
+		TeamName: r.TunEx.AccessTeamName,
+		AudTag:   []string{r.TunEx.AccessApplicationAUD},
+	}
+	return originRequest
+}
 
-	if r.TunEx.TunSpec.Container != nil {
-		if r.TunEx.TunSpec.Container.Image != "" {
-			tunnelDeploymentModel.Image = r.TunEx.TunSpec.Container.Image
-		}
-		if r.TunEx.TunSpec.Container.ImagePullPolicy != "" {
-			tunnelDeploymentModel.ImagePullPolicy = r.TunEx.TunSpec.Container.ImagePullPolicy
-		}
-		if len(r.TunEx.TunSpec.Container.Command) != 0 {
-			tunnelDeploymentModel.Command = r.TunEx.TunSpec.Container.Command
+// createWorkload creates the Deployment or DaemonSet running the connectors and removes the one of the other kind
+func (r *CloudflareTunnelReconciler) createWorkload(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel, secret *corev1.Secret, configMap *corev1.ConfigMap) (workloadReadiness, error) {
+	tunnelDeploymentModel := r.deploymentModel(secret, configMap)
+	name := types.NamespacedName{Name: r.TunEx.Name + "-" + constants.ResourceSuffix, Namespace: r.TunEx.Namespace}
+
+	if r.isDaemonSet() {
+		if err := r.deleteUnused(ctx, name, &appsv1.Deployment{}); err != nil {
+			return workloadReadiness{}, err
 		}
-		if len(r.TunEx.TunSpec.Container.Args) != 0 {
-			tunnelDeploymentModel.Args = r.TunEx.TunSpec.Container.Args
+		daemonSet, err := r.createDaemonSet(ctx, cloudflareTunnel, tunnelDeploymentModel)
+		if err != nil {
+			return workloadReadiness{}, err
 		}
+		return workloadReadiness{
+			Desired:  daemonSet.Status.DesiredNumberScheduled,
+			Ready:    daemonSet.Status.NumberReady,
+			UpToDate: daemonSet.Status.ObservedGeneration >= daemonSet.Generation,
+		}, nil
 	}
 
+	if err := r.deleteUnused(ctx, name, &appsv1.DaemonSet{}); err != nil {
+		return workloadReadiness{}, err
+	}
+	deployment, err := r.createDeployment(ctx, cloudflareTunnel, tunnelDeploymentModel)
+	if err != nil {
+		return workloadReadiness{}, err
+	}
+	// the replicas of the deployment are used since they might be managed by an autoscaler
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return workloadReadiness{
+		Desired:  replicas,
+		Ready:    deployment.Status.ReadyReplicas,
+		UpToDate: deployment.Status.ObservedGeneration >= deployment.Generation,
+	}, nil
+}
+
+// deleteUnused deletes an owned object no longer in use, e.g. the Deployment after switching to a DaemonSet
+func (r *CloudflareTunnelReconciler) deleteUnused(ctx context.Context, name types.NamespacedName, object client.Object) error {
+	if err := r.Client.Get(ctx, name, object); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	// the type meta is not filled in for typed objects, so the kind is looked up in the scheme
+	kind := object.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(object, r.Scheme); err == nil {
+		kind = gvk.Kind
+	}
+	r.logger.Info("deleting unused " + kind + "...")
+	if err := r.Client.Delete(ctx, object); err != nil && !errors.IsNotFound(err) {
+		r.logger.Error(err, "could not delete unused "+kind)
+		return err
+	}
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) deploymentModel(secret *corev1.Secret, configMap *corev1.ConfigMap) models.DeploymentModel {
+	// spreading only makes sense with more than one replica and is on unless explicitly disabled
+	highAvailability := r.TunEx.TunSpec.HighAvailability
+	spreadPods := !r.isDaemonSet() && r.maxReplicas() > 1 && (highAvailability == nil || highAvailability.SpreadPods == nil || *highAvailability.SpreadPods)
+
+	var socketServices []*cfv1beta1.CloudflareTunnelService
+	for i := range r.TunEx.TunSpec.Ingress {
+		socketServices = append(socketServices, &r.TunEx.TunSpec.Ingress[i].Service)
+	}
+
+	tunnelDeploymentModel := models.DeploymentModel{
+		Name:            r.TunEx.Name,
+		Namespace:       r.TunEx.Namespace,
+		Replicas:        &r.TunEx.TunSpec.Replicas,
+		TunnelID:        r.TunEx.TunnelID,
+		Image:           r.TunEx.TunSpec.Container.Image,
+		ImagePullPolicy: r.TunEx.TunSpec.Container.ImagePullPolicy,
+		Command:         r.TunEx.TunSpec.Container.Command,
+		Args:            r.TunEx.TunSpec.Container.Args,
+		CredentialsType: r.TunEx.TunSpec.CredentialsType,
+		OriginCA:        r.TunEx.TunSpec.OriginCA,
+		SocketServices:  socketServices,
+		PodTemplate:     r.TunEx.TunSpec.PodTemplate,
+		Probes:          r.TunEx.TunSpec.Probes,
+		ServesMetrics:   r.TunEx.TunSpec.Container.ServesMetrics(),
+		SpreadPods:      spreadPods,
+		Secret:          secret,
+		ConfigMap:       configMap,
+	}
+
+	if r.TunEx.TunSpec.Autoscaling != nil || r.isDaemonSet() {
+		tunnelDeploymentModel.Replicas = nil
+	}
+	return tunnelDeploymentModel
+}
+
+func (r *CloudflareTunnelReconciler) createDeployment(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel, tunnelDeploymentModel models.DeploymentModel) (*appsv1.Deployment, error) {
+	var deploymentFetch appsv1.Deployment
 	deploymentCreate := models.Deployment(tunnelDeploymentModel).GetDeployment()
 
 	// the secret needs to have an owner reference back to the controller
@@ -443,6 +864,10 @@ func (r *CloudflareTunnelReconciler) createDeployment(ctx context.Context, cloud
 		}
 	} else {
 		// deployment exists, so update it to ensure it is consistent
+		if deploymentCreate.Spec.Replicas == nil {
+			// the autoscaler owns the replicas, so they must not be reset on every reconcile
+			deploymentCreate.Spec.Replicas = deploymentFetch.Spec.Replicas
+		}
 		if err := r.Client.Update(ctx, deploymentCreate); err != nil {
 			r.logger.Error(err, "could not update deployment")
 			return nil, err
@@ -451,54 +876,332 @@ func (r *CloudflareTunnelReconciler) createDeployment(ctx context.Context, cloud
 	return deploymentCreate, nil
 }
 
-func (r *CloudflareTunnelReconciler) getTargetURL(ctx context.Context) (string, error) {
+func (r *CloudflareTunnelReconciler) createDaemonSet(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel, tunnelDeploymentModel models.DeploymentModel) (*appsv1.DaemonSet, error) {
+	var daemonSetFetch appsv1.DaemonSet
+	daemonSetCreate := models.Deployment(tunnelDeploymentModel).GetDaemonSet()
+
+	// the daemonset needs to have an owner reference back to the controller
+	if err := ctrl.SetControllerReference(&cloudflareTunnel, daemonSetCreate, r.Scheme); err != nil {
+		r.logger.Error(err, "could not create controller reference in daemonset")
+		return nil, err
+	}
+	r.logger.V(1).Info("Owner Reference for daemonset created")
+
+	// try to get an existing daemonset with the given name
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: daemonSetCreate.Name, Namespace: r.TunEx.Namespace}, &daemonSetFetch); err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.Info("creating daemonset...")
+			if err := r.Client.Create(ctx, daemonSetCreate); err != nil {
+				r.logger.Error(err, "could not create daemonset in cluster")
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	} else {
+		// daemonset exists, so update it to ensure it is consistent
+		if err := r.Client.Update(ctx, daemonSetCreate); err != nil {
+			r.logger.Error(err, "could not update daemonset")
+			return nil, err
+		}
+	}
+	return daemonSetCreate, nil
+}
+
+// isDaemonSet checks if the connectors run as a DaemonSet instead of a Deployment
+func (r *CloudflareTunnelReconciler) isDaemonSet() bool {
+	return r.TunEx.TunSpec.WorkloadKind == constants.WorkloadKindDaemonSet
+}
+
+func (r *CloudflareTunnelReconciler) createPodDisruptionBudget(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel) error {
+	var pdbFetch policyv1.PodDisruptionBudget
+
+	pdbModel := models.PodDisruptionBudgetModel{
+		Name:      r.TunEx.Name,
+		Namespace: r.TunEx.Namespace,
+	}
+	// a budget for a single replica would block node drains entirely, so it is only created for more replicas
+	highAvailability := r.TunEx.TunSpec.HighAvailability
+	// daemonset pods are not evicted by node drains, so they need no budget either
+	desired := r.maxReplicas() > 1 && !r.isDaemonSet()
+	if highAvailability != nil {
+		pdbModel.MaxUnavailable = highAvailability.MaxUnavailable
+		if highAvailability.PodDisruptionBudget != nil && !*highAvailability.PodDisruptionBudget {
+			desired = false
+		}
+	}
+	pdbCreate := models.PodDisruptionBudget(pdbModel).GetPodDisruptionBudget()
+
+	// try to get an existing budget with the given name
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: pdbCreate.Name, Namespace: r.TunEx.Namespace}, &pdbFetch); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if !desired {
+			return nil
+		}
+		// the budget needs to have an owner reference back to the controller
+		if err := ctrl.SetControllerReference(&cloudflareTunnel, pdbCreate, r.Scheme); err != nil {
+			r.logger.Error(err, "could not create controller reference in PodDisruptionBudget")
+			return err
+		}
+		r.logger.Info("creating PodDisruptionBudget...")
+		if err := r.Client.Create(ctx, pdbCreate); err != nil {
+			r.logger.Error(err, "could not create PodDisruptionBudget in cluster")
+			return err
+		}
+		return nil
+	}
+
+	if !desired {
+		r.logger.Info("deleting PodDisruptionBudget...")
+		if err := r.Client.Delete(ctx, &pdbFetch); err != nil && !errors.IsNotFound(err) {
+			r.logger.Error(err, "could not delete PodDisruptionBudget")
+			return err
+		}
+		return nil
+	}
+
+	// budget exists, so update it to ensure it is consistent
+	if err := ctrl.SetControllerReference(&cloudflareTunnel, pdbCreate, r.Scheme); err != nil {
+		r.logger.Error(err, "could not create controller reference in PodDisruptionBudget")
+		return err
+	}
+	pdbCreate.ResourceVersion = pdbFetch.ResourceVersion
+	if err := r.Client.Update(ctx, pdbCreate); err != nil {
+		r.logger.Error(err, "could not update PodDisruptionBudget")
+		return err
+	}
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) createHorizontalPodAutoscaler(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel) error {
+	var hpaFetch autoscalingv2.HorizontalPodAutoscaler
+
+	autoscaling := r.TunEx.TunSpec.Autoscaling
+	if r.isDaemonSet() {
+		// a daemonset cannot be scaled, it runs a connector on every matching node
+		autoscaling = nil
+	}
+	hpaName := r.TunEx.Name + "-" + constants.ResourceSuffix
+
+	// try to get an existing autoscaler with the given name
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: hpaName, Namespace: r.TunEx.Namespace}, &hpaFetch); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		if autoscaling == nil {
+			return nil
+		}
+		hpaCreate, err := r.horizontalPodAutoscaler(cloudflareTunnel)
+		if err != nil {
+			return err
+		}
+		r.logger.Info("creating HorizontalPodAutoscaler...")
+		if err := r.Client.Create(ctx, hpaCreate); err != nil {
+			r.logger.Error(err, "could not create HorizontalPodAutoscaler in cluster")
+			return err
+		}
+		return nil
+	}
+
+	if autoscaling == nil {
+		// the replicas of the spec are applied to the deployment again
+		r.logger.Info("deleting HorizontalPodAutoscaler...")
+		if err := r.Client.Delete(ctx, &hpaFetch); err != nil && !errors.IsNotFound(err) {
+			r.logger.Error(err, "could not delete HorizontalPodAutoscaler")
+			return err
+		}
+		return nil
+	}
+
+	// autoscaler exists, so update it to ensure it is consistent
+	hpaCreate, err := r.horizontalPodAutoscaler(cloudflareTunnel)
+	if err != nil {
+		return err
+	}
+	hpaCreate.ResourceVersion = hpaFetch.ResourceVersion
+	if err := r.Client.Update(ctx, hpaCreate); err != nil {
+		r.logger.Error(err, "could not update HorizontalPodAutoscaler")
+		return err
+	}
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) horizontalPodAutoscaler(cloudflareTunnel cfv1beta1.CloudflareTunnel) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	hpa := models.HorizontalPodAutoscaler(models.HorizontalPodAutoscalerModel{
+		Name:        r.TunEx.Name,
+		Namespace:   r.TunEx.Namespace,
+		Autoscaling: r.TunEx.TunSpec.Autoscaling,
+	}).GetHorizontalPodAutoscaler()
+
+	// the autoscaler needs to have an owner reference back to the controller
+	if err := ctrl.SetControllerReference(&cloudflareTunnel, hpa, r.Scheme); err != nil {
+		r.logger.Error(err, "could not create controller reference in HorizontalPodAutoscaler")
+		return nil, err
+	}
+	return hpa, nil
+}
+
+// maxReplicas returns the highest number of replicas the connectors can run with
+func (r *CloudflareTunnelReconciler) maxReplicas() int32 {
+	if r.TunEx.TunSpec.Autoscaling != nil {
+		return r.TunEx.TunSpec.Autoscaling.MaxReplicas
+	}
+	return r.TunEx.TunSpec.Replicas
+}
+
+func (r *CloudflareTunnelReconciler) validateOriginCA(ctx context.Context) error {
+	originCA := r.TunEx.TunSpec.OriginCA
+	if originCA == nil {
+		return nil
+	}
+	// exactly one of secretName or configMapName must be set
+	if (originCA.SecretName == "") == (originCA.ConfigMapName == "") {
+		err := fmt.Errorf("invalid originCA")
+		r.logger.Error(err, "exactly one of secretName or configMapName must be set in originCA")
+		return err
+	}
+
+	var found bool
+	if originCA.SecretName != "" {
+		var secret corev1.Secret
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: originCA.SecretName, Namespace: r.TunEx.Namespace}, &secret); err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Error(err, "could not find origin CA secret with name "+originCA.SecretName)
+			}
+			return err
+		}
+		_, found = secret.Data[originCA.Key]
+	} else {
+		var configMap corev1.ConfigMap
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: originCA.ConfigMapName, Namespace: r.TunEx.Namespace}, &configMap); err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Error(err, "could not find origin CA configMap with name "+originCA.ConfigMapName)
+			}
+			return err
+		}
+		_, found = configMap.Data[originCA.Key]
+	}
+	if !found {
+		err := fmt.Errorf("invalid key")
+		r.logger.Error(err, "key "+originCA.Key+" not found in origin CA")
+		return err
+	}
+	r.logger.V(1).Info("Origin CA validated")
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) getTargetURL(ctx context.Context, service *cfv1beta1.CloudflareTunnelService) (string, error) {
+	// services that are not reached over the network don't need any lookup
+	switch service.Protocol {
+	case cfv1beta1.ProtocolHelloWorld:
+		return cfv1beta1.ProtocolHelloWorld, nil
+	case cfv1beta1.ProtocolHTTPStatus:
+		if service.StatusCode == 0 {
+			err := fmt.Errorf("statusCode key does not exist")
+			r.logger.Error(err, "statusCode is required for the http_status protocol")
+			return "", err
+		}
+		return cfv1beta1.ProtocolHTTPStatus + ":" + strconv.Itoa(int(service.StatusCode)), nil
+	case cfv1beta1.ProtocolUnix:
+		if service.Path == "" {
+			err := fmt.Errorf("path key does not exist")
+			r.logger.Error(err, "path is required for the unix protocol")
+			return "", err
+		}
+		return cfv1beta1.ProtocolUnix + ":" + service.Path, nil
+	}
+
+	port := strconv.Itoa(int(service.Port))
+
+	// an explicit host can reach a service of another namespace through the DNS of the cluster as well
+	target, ok, err := service.TargetService(ctx, r.APIReader, r.TunEx.Namespace)
+	if err != nil {
+		r.logger.Error(err, "could not check if the host refers to a service")
+		return "", err
+	}
+	if ok {
+		if err := r.checkReferenceGrant(ctx, target); err != nil {
+			return "", err
+		}
+	}
+
+	// an explicit host is used as is without looking up any service
+	if service.Host != "" {
+		return service.Protocol + "://" + service.Host + ":" + port, nil
+	}
+
+	namespace := service.Namespace
+	if namespace == "" {
+		namespace = r.TunEx.Namespace
+	}
+
 	// first get the url for the targeted service
 	var targetService corev1.Service
 	if err := r.Client.Get(ctx, types.NamespacedName{
-		Name:      r.TunEx.TunSpec.Service.Name,
-		Namespace: r.TunEx.TunSpec.Service.Namespace,
+		Name:      service.Name,
+		Namespace: namespace,
 	}, &targetService); err != nil {
 		if errors.IsNotFound(err) {
 			// error due to service not being present
 			r.logger.Error(err, "target service not present")
 		}
 		return "", err
-	} else {
-		// service exists, check if port is open
-		var port corev1.ServicePort
-		for _, servicePort := range targetService.Spec.Ports {
-			if servicePort.Port == r.TunEx.TunSpec.Service.Port {
-				r.logger.V(1).Info("Ports matched")
-				port = servicePort
-				break
-			}
-		}
-		if &port == nil {
-			r.logger.Error(err, "port doesn't exist in service")
-			return "", err
+	}
+
+	// service exists, check if port is open
+	portFound := false
+	for _, servicePort := range targetService.Spec.Ports {
+		if servicePort.Port == service.Port {
+			r.logger.V(1).Info("Ports matched")
+			portFound = true
+			break
 		}
 	}
+	if !portFound {
+		err := fmt.Errorf("invalid port")
+		r.logger.Error(err, "port doesn't exist in service")
+		return "", err
+	}
 
 	// if the service is a LoadBalancer then use the ingress IP as the host
-	if targetService.Spec.Type == corev1.ServiceTypeLoadBalancer {
-		return r.TunEx.TunSpec.Service.Protocol + "://" + targetService.Status.LoadBalancer.Ingress[0].IP + ":" + strconv.Itoa(int(r.TunEx.TunSpec.Service.Port)), nil
+	if targetService.Spec.Type == corev1.ServiceTypeLoadBalancer && len(targetService.Status.LoadBalancer.Ingress) != 0 {
+		return service.Protocol + "://" + targetService.Status.LoadBalancer.Ingress[0].IP + ":" + port, nil
 	}
 	// else generate the URL of the form `service-name.namespace:port`
 	// see https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/#a-aaaa-records
-	return r.TunEx.TunSpec.Service.Protocol + "://" + r.TunEx.TunSpec.Service.Name + "." + r.TunEx.TunSpec.Service.Namespace + ":" + strconv.Itoa(int(r.TunEx.TunSpec.Service.Port)), nil
+	return service.Protocol + "://" + service.Name + "." + namespace + ":" + port, nil
+}
+
+// recordRemoteIDs writes the ids of the resources just created in the remote to the status right away, so that they
+// are not lost when the reconcile fails later on
+func (r *CloudflareTunnelReconciler) recordRemoteIDs(ctx context.Context, cloudflareTunnel *cfv1beta1.CloudflareTunnel) error {
+	cloudflareTunnel.Status.TunnelID = r.TunEx.TunnelID
+	cloudflareTunnel.Status.RemoteName = r.TunEx.RemoteName
+	cloudflareTunnel.Status.Adopted = r.TunEx.Adopted
+	cloudflareTunnel.Status.AccessApplicationID = r.TunEx.AccessApplicationID
+	cloudflareTunnel.Status.AccessApplicationAUD = r.TunEx.AccessApplicationAUD
+	if err := r.Client.Status().Update(ctx, cloudflareTunnel); err != nil {
+		r.logger.Error(err, "could not record the ids of the remote resources")
+		return err
+	}
+	return nil
 }
 
-func (r *CloudflareTunnelReconciler) updateStatus(ctx context.Context, cloudflareTunnel *cfv1.CloudflareTunnel) error {
+func (r *CloudflareTunnelReconciler) updateStatus(ctx context.Context, cloudflareTunnel *cfv1beta1.CloudflareTunnel, readiness workloadReadiness) error {
 	accountResourceContainer := cloudflare.AccountIdentifier(r.TunEx.CloudflareAPI.AccountID)
 	tunnelConnections, err := r.TunEx.CloudflareAPI.TunnelConnections(ctx, accountResourceContainer, r.TunEx.TunnelID)
 	if err != nil {
 		r.logger.Error(err, "could not fetch tunnel connections")
 		return err
 	}
-	var connections []cfv1.CloudflareTunnelConnections
+	var connections []cfv1beta1.CloudflareTunnelConnections
+	connectorVersions := map[string]int{}
 	for _, connectionMeta := range tunnelConnections { // 0 index since it will always return a single tunnel
+		connectorVersions[connectionMeta.Version]++
 		for _, connection := range connectionMeta.Connections {
-			connections = append(connections, cfv1.CloudflareTunnelConnections{
+			connections = append(connections, cfv1beta1.CloudflareTunnelConnections{
 				ConnectorID:  connectionMeta.ID,
 				Created:      metav1.Time{Time: *connectionMeta.RunAt},
 				Architecture: connectionMeta.Arch,
@@ -508,10 +1211,41 @@ func (r *CloudflareTunnelReconciler) updateStatus(ctx context.Context, cloudflar
 			})
 		}
 	}
-	cloudflareTunnel.Status = cfv1.CloudflareTunnelStatus{
-		TunnelID:    r.TunEx.TunnelID,
-		Connections: connections,
+	cloudflareTunnel.Status.TunnelID = r.TunEx.TunnelID
+	cloudflareTunnel.Status.RemoteName = r.TunEx.RemoteName
+	cloudflareTunnel.Status.Adopted = r.TunEx.Adopted
+	cloudflareTunnel.Status.Connections = connections
+	setTunnelMetrics(types.NamespacedName{Name: cloudflareTunnel.Name, Namespace: cloudflareTunnel.Namespace}, len(connections), connectorVersions)
+	cloudflareTunnel.Status.Routes = r.TunEx.Routes
+	cloudflareTunnel.Status.AccessApplicationID = r.TunEx.AccessApplicationID
+	cloudflareTunnel.Status.AccessApplicationAUD = r.TunEx.AccessApplicationAUD
+
+	// the pods only become ready once cloudflared has a connection to the edge
+	readyCondition := metav1.Condition{
+		Type:               constants.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             constants.ReasonConnectorsNotReady,
+		Message:            fmt.Sprintf("%d/%d connectors ready", readiness.Ready, readiness.Desired),
+		ObservedGeneration: cloudflareTunnel.Generation,
+	}
+	if readiness.Ready >= readiness.Desired && readiness.UpToDate {
+		readyCondition.Status = metav1.ConditionTrue
+		readyCondition.Reason = constants.ReasonConnectorsReady
+	}
+	meta.SetStatusCondition(&cloudflareTunnel.Status.Conditions, readyCondition)
+	resolvedRefsCondition := metav1.Condition{
+		Type:               constants.ConditionResolvedRefs,
+		Status:             metav1.ConditionTrue,
+		Reason:             constants.ReasonResolvedRefs,
+		Message:            "all services of the ingress rules are resolved",
+		ObservedGeneration: cloudflareTunnel.Generation,
+	}
+	if len(r.TunEx.RefErrors) != 0 {
+		resolvedRefsCondition.Status = metav1.ConditionFalse
+		resolvedRefsCondition.Reason = constants.ReasonRefNotPermitted
+		resolvedRefsCondition.Message = strings.Join(r.TunEx.RefErrors, "; ")
 	}
+	meta.SetStatusCondition(&cloudflareTunnel.Status.Conditions, resolvedRefsCondition)
 	return nil
 }
 
@@ -0,0 +1,179 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+)
+
+func TestGetTargetURL(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	service := func(namespace string, name string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+		}
+	}
+	loadBalancer := service("tunnels", "lb")
+	loadBalancer.Spec.Type = corev1.ServiceTypeLoadBalancer
+	loadBalancer.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "192.0.2.10"}}
+	grant := func(namespace string, serviceName string) *cfv1beta1.ReferenceGrant {
+		return &cfv1beta1.ReferenceGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "tunnels-" + serviceName, Namespace: namespace},
+			Spec: cfv1beta1.ReferenceGrantSpec{
+				From: []cfv1beta1.ReferenceGrantFrom{{Group: cfv1beta1.GroupVersion.Group, Kind: "CloudflareTunnel", Namespace: "tunnels"}},
+				To:   []cfv1beta1.ReferenceGrantTo{{Group: "", Kind: "Service", Name: serviceName}},
+			},
+		}
+	}
+	objects := []client.Object{
+		service("tunnels", "app"),
+		loadBalancer,
+		service("granted", "app"),
+		grant("granted", "app"),
+		service("other", "app"),
+		grant("other", "another-app"),
+	}
+
+	tests := []struct {
+		name       string
+		service    cfv1beta1.CloudflareTunnelService
+		want       string
+		wantErr    bool
+		notAllowed bool
+	}{
+		{
+			name:    "service of the namespace",
+			service: cfv1beta1.CloudflareTunnelService{Name: "app", Protocol: "http", Port: 80},
+			want:    "http://app.tunnels:80",
+		},
+		{
+			name:    "port the service does not expose",
+			service: cfv1beta1.CloudflareTunnelService{Name: "app", Protocol: "http", Port: 8080},
+			wantErr: true,
+		},
+		{
+			name:    "missing service",
+			service: cfv1beta1.CloudflareTunnelService{Name: "missing", Protocol: "http", Port: 80},
+			wantErr: true,
+		},
+		{
+			name:    "load balancer",
+			service: cfv1beta1.CloudflareTunnelService{Name: "lb", Protocol: "https", Port: 80},
+			want:    "https://192.0.2.10:80",
+		},
+		{
+			name:    "service of another namespace with a grant",
+			service: cfv1beta1.CloudflareTunnelService{Name: "app", Namespace: "granted", Protocol: "http", Port: 80},
+			want:    "http://app.granted:80",
+		},
+		{
+			name:       "service of another namespace without a grant",
+			service:    cfv1beta1.CloudflareTunnelService{Name: "app", Namespace: "other", Protocol: "http", Port: 80},
+			wantErr:    true,
+			notAllowed: true,
+		},
+		{
+			name:    "external host",
+			service: cfv1beta1.CloudflareTunnelService{Host: "example.com", Protocol: "https", Port: 443},
+			want:    "https://example.com:443",
+		},
+		{
+			name:    "host of a service of the namespace",
+			service: cfv1beta1.CloudflareTunnelService{Host: "app", Protocol: "http", Port: 80},
+			want:    "http://app:80",
+		},
+		{
+			name:    "host of a service of another namespace with a grant",
+			service: cfv1beta1.CloudflareTunnelService{Host: "app.granted.svc", Protocol: "http", Port: 80},
+			want:    "http://app.granted.svc:80",
+		},
+		{
+			name:       "fully qualified host of a service of another namespace without a grant",
+			service:    cfv1beta1.CloudflareTunnelService{Host: "app.other.svc.cluster.local", Protocol: "http", Port: 80},
+			wantErr:    true,
+			notAllowed: true,
+		},
+		{
+			name:       "short host of a service of another namespace without a grant",
+			service:    cfv1beta1.CloudflareTunnelService{Host: "app.other", Protocol: "http", Port: 80},
+			wantErr:    true,
+			notAllowed: true,
+		},
+		{
+			name:    "ip address",
+			service: cfv1beta1.CloudflareTunnelService{Host: "10.0.0.1", Protocol: "tcp", Port: 22},
+			want:    "tcp://10.0.0.1:22",
+		},
+		{
+			name:    "status code",
+			service: cfv1beta1.CloudflareTunnelService{Protocol: cfv1beta1.ProtocolHTTPStatus, StatusCode: 418},
+			want:    "http_status:418",
+		},
+		{
+			name:    "hello world",
+			service: cfv1beta1.CloudflareTunnelService{Protocol: cfv1beta1.ProtocolHelloWorld},
+			want:    "hello_world",
+		},
+		{
+			name:    "unix socket",
+			service: cfv1beta1.CloudflareTunnelService{Protocol: cfv1beta1.ProtocolUnix, Path: "/run/app.sock"},
+			want:    "unix:/run/app.sock",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := logr.Discard()
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+			r := &CloudflareTunnelReconciler{
+				Client:    c,
+				APIReader: c,
+				TunEx:     &TunnelExpanded{Name: "tunnel", Namespace: "tunnels"},
+				logger:    &logger,
+			}
+			got, err := r.getTargetURL(context.Background(), &tt.service)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getTargetURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if notAllowed := goerrors.Is(err, errRefNotPermitted); notAllowed != tt.notAllowed {
+				t.Fatalf("getTargetURL() error = %v, want errRefNotPermitted %v", err, tt.notAllowed)
+			}
+			if got != tt.want {
+				t.Errorf("getTargetURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
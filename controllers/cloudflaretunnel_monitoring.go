@@ -0,0 +1,144 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/models"
+)
+
+// createMonitoring creates the metrics service and the monitor for the Prometheus Operator
+// both are removed again once the monitoring block is removed from the spec
+func (r *CloudflareTunnelReconciler) createMonitoring(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel) error {
+	monitoring := r.TunEx.TunSpec.Monitoring
+
+	serviceCreate := models.MetricsService(models.MetricsServiceModel{
+		Name:      r.TunEx.Name,
+		Namespace: r.TunEx.Namespace,
+	}).GetService()
+	if monitoring == nil {
+		if err := r.deleteUnused(ctx, client.ObjectKeyFromObject(serviceCreate), &corev1.Service{}); err != nil {
+			return err
+		}
+	} else if err := r.createMetricsService(ctx, cloudflareTunnel, serviceCreate); err != nil {
+		return err
+	}
+
+	// the monitor of the other kind is removed as well, e.g. after switching from ServiceMonitor to PodMonitor
+	monitorName := types.NamespacedName{Name: r.TunEx.Name + "-" + constants.ResourceSuffix, Namespace: r.TunEx.Namespace}
+	for _, kind := range []string{constants.MonitorKindServiceMonitor, constants.MonitorKindPodMonitor} {
+		if monitoring != nil && monitoring.Kind == kind {
+			continue
+		}
+		monitor := &unstructured.Unstructured{}
+		monitor.SetGroupVersionKind(models.MonitorGroupVersionKind(kind))
+		if err := r.deleteUnused(ctx, monitorName, monitor); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	if monitoring == nil {
+		return nil
+	}
+
+	monitorCreate, err := models.Monitor(models.MonitorModel{
+		Name:       r.TunEx.Name,
+		Namespace:  r.TunEx.Namespace,
+		TunnelID:   r.TunEx.TunnelID,
+		Monitoring: monitoring,
+	}).GetMonitor()
+	if err != nil {
+		r.logger.Error(err, "could not generate monitor")
+		return err
+	}
+
+	// the monitor needs to have an owner reference back to the controller
+	if err := ctrl.SetControllerReference(&cloudflareTunnel, monitorCreate, r.Scheme); err != nil {
+		r.logger.Error(err, "could not create controller reference in monitor")
+		return err
+	}
+
+	monitorFetch := &unstructured.Unstructured{}
+	monitorFetch.SetGroupVersionKind(monitorCreate.GroupVersionKind())
+	if err := r.Client.Get(ctx, monitorName, monitorFetch); err != nil {
+		if meta.IsNoMatchError(err) {
+			// the Prometheus Operator is not installed, so there is nothing that could pick up the monitor
+			r.logger.Info("CRD for " + monitoring.Kind + " is not installed, skipping the monitor")
+			return nil
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		r.logger.Info("creating " + monitoring.Kind + "...")
+		if err := r.Client.Create(ctx, monitorCreate); err != nil {
+			r.logger.Error(err, "could not create "+monitoring.Kind+" in cluster")
+			return err
+		}
+		return nil
+	}
+
+	// monitor exists, so update it to ensure it is consistent
+	monitorCreate.SetResourceVersion(monitorFetch.GetResourceVersion())
+	if err := r.Client.Update(ctx, monitorCreate); err != nil {
+		r.logger.Error(err, "could not update "+monitoring.Kind)
+		return err
+	}
+	return nil
+}
+
+func (r *CloudflareTunnelReconciler) createMetricsService(ctx context.Context, cloudflareTunnel cfv1beta1.CloudflareTunnel, serviceCreate *corev1.Service) error {
+	var serviceFetch corev1.Service
+
+	// the service needs to have an owner reference back to the controller
+	if err := ctrl.SetControllerReference(&cloudflareTunnel, serviceCreate, r.Scheme); err != nil {
+		r.logger.Error(err, "could not create controller reference in metrics service")
+		return err
+	}
+
+	// try to get an existing service with the given name
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(serviceCreate), &serviceFetch); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		r.logger.Info("creating metrics service...")
+		if err := r.Client.Create(ctx, serviceCreate); err != nil {
+			r.logger.Error(err, "could not create metrics service in cluster")
+			return err
+		}
+		return nil
+	}
+
+	// service exists, so update it while keeping the fields allocated by the cluster
+	serviceCreate.ResourceVersion = serviceFetch.ResourceVersion
+	serviceCreate.Spec.ClusterIP = serviceFetch.Spec.ClusterIP
+	serviceCreate.Spec.ClusterIPs = serviceFetch.Spec.ClusterIPs
+	if err := r.Client.Update(ctx, serviceCreate); err != nil {
+		r.logger.Error(err, "could not update metrics service")
+		return err
+	}
+	return nil
+}
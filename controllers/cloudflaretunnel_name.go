@@ -0,0 +1,81 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultTunnelNameTemplate qualifies the remote name of a tunnel with the cluster and the namespace, so that
+// resources of the same name in different namespaces or clusters don't share a tunnel of the account
+const DefaultTunnelNameTemplate = "{{ with .ClusterID }}{{ . }}-{{ end }}{{ .Namespace }}-{{ .Name }}"
+
+// TunnelNameData is passed to the template naming the remote tunnels
+type TunnelNameData struct {
+	ClusterID string // identifies the cluster when several clusters share an account, may be empty
+	Namespace string
+	Name      string
+}
+
+// ParseTunnelNameTemplate parses the template naming the remote tunnels and checks that it can be rendered
+func ParseTunnelNameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("tunnelName").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := renderTunnelName(tmpl, TunnelNameData{Namespace: "namespace", Name: "name"}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func renderTunnelName(tmpl *template.Template, data TunnelNameData) (string, error) {
+	var name strings.Builder
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(name.String()) == "" {
+		return "", fmt.Errorf("tunnel name template rendered an empty name")
+	}
+	return strings.TrimSpace(name.String()), nil
+}
+
+// remoteTunnelName returns the name of the tunnel in the remote, which stays the one in the status once chosen
+func (r *CloudflareTunnelReconciler) remoteTunnelName() (string, error) {
+	if r.TunEx.RemoteName != "" {
+		return r.TunEx.RemoteName, nil
+	}
+	tmpl := r.TunnelNameTemplate
+	if tmpl == nil {
+		var err error
+		if tmpl, err = ParseTunnelNameTemplate(DefaultTunnelNameTemplate); err != nil {
+			return "", err
+		}
+	}
+	name, err := renderTunnelName(tmpl, TunnelNameData{
+		ClusterID: r.ClusterID,
+		Namespace: r.TunEx.Namespace,
+		Name:      r.TunEx.Name,
+	})
+	if err != nil {
+		r.logger.Error(err, "could not render the tunnel name")
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import "testing"
+
+func TestRenderTunnelName(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     TunnelNameData
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "default without cluster id",
+			template: DefaultTunnelNameTemplate,
+			data:     TunnelNameData{Namespace: "default", Name: "tunnel"},
+			want:     "default-tunnel",
+		},
+		{
+			name:     "default with cluster id",
+			template: DefaultTunnelNameTemplate,
+			data:     TunnelNameData{ClusterID: "prod", Namespace: "default", Name: "tunnel"},
+			want:     "prod-default-tunnel",
+		},
+		{
+			name:     "custom template",
+			template: "k8s.{{ .Name }}.{{ .Namespace }}",
+			data:     TunnelNameData{Namespace: "default", Name: "tunnel"},
+			want:     "k8s.tunnel.default",
+		},
+		{
+			name:     "surrounding whitespace is trimmed",
+			template: " {{ .Name }}\n",
+			data:     TunnelNameData{Namespace: "default", Name: "tunnel"},
+			want:     "tunnel",
+		},
+		{
+			name:     "empty name",
+			template: "{{ if ne .Namespace \"default\" }}{{ .Name }}{{ end }}",
+			data:     TunnelNameData{Namespace: "default", Name: "tunnel"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTunnelNameTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("ParseTunnelNameTemplate(%q) failed: %v", tt.template, err)
+			}
+			got, err := renderTunnelName(tmpl, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderTunnelName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderTunnelName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTunnelNameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "default", template: DefaultTunnelNameTemplate},
+		{name: "syntax error", template: "{{ .Name ", wantErr: true},
+		{name: "unknown field", template: "{{ .Cluster }}-{{ .Name }}", wantErr: true},
+		{name: "empty name", template: "{{ .ClusterID }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTunnelNameTemplate(tt.template); (err != nil) != tt.wantErr {
+				t.Errorf("ParseTunnelNameTemplate(%q) error = %v, wantErr %v", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+)
+
+// errRefNotPermitted is returned for a service in another namespace that no ReferenceGrant allows the tunnel to use
+var errRefNotPermitted = goerrors.New("reference not permitted")
+
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=referencegrants,verbs=get;list;watch
+
+// checkReferenceGrant returns errRefNotPermitted unless a ReferenceGrant in the namespace of the service allows the
+// namespace of the tunnel to reference it
+func (r *CloudflareTunnelReconciler) checkReferenceGrant(ctx context.Context, service types.NamespacedName) error {
+	permitted, err := cfv1beta1.ReferencePermitted(ctx, r.Client, r.TunEx.Namespace, service)
+	if err != nil {
+		r.logger.Error(err, "could not list ReferenceGrants")
+		return err
+	}
+	if permitted {
+		return nil
+	}
+	err = fmt.Errorf("%w: no ReferenceGrant in namespace %s allows tunnels from namespace %s to reference service %s", errRefNotPermitted, service.Namespace, r.TunEx.Namespace, service.Name)
+	r.logger.Error(err, "cross namespace reference not permitted")
+	return err
+}
+
+// tunnelsForReferenceGrant enqueues the tunnels of the namespaces a ReferenceGrant refers to, so that they pick up
+// grants being created or removed
+func (r *CloudflareTunnelReconciler) tunnelsForReferenceGrant(object client.Object) []reconcile.Request {
+	referenceGrant, ok := object.(*cfv1beta1.ReferenceGrant)
+	if !ok {
+		return nil
+	}
+	ctx := context.Background()
+	var requests []reconcile.Request
+	for _, from := range referenceGrant.Spec.From {
+		var cloudflareTunnels cfv1beta1.CloudflareTunnelList
+		if err := r.Client.List(ctx, &cloudflareTunnels, client.InNamespace(from.Namespace)); err != nil {
+			log.FromContext(ctx).Error(err, "could not list CloudflareTunnels for ReferenceGrant "+referenceGrant.Name)
+			continue
+		}
+		for _, cloudflareTunnel := range cloudflareTunnels.Items {
+			if !inShard(r.ShardSelector, &cloudflareTunnel) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      cloudflareTunnel.Name,
+				Namespace: cloudflareTunnel.Namespace,
+			}})
+		}
+	}
+	return requests
+}
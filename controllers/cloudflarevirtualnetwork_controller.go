@@ -0,0 +1,242 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cloudflare/cloudflare-go"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cfv1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+// CloudflareVirtualNetworkReconciler reconciles a CloudflareVirtualNetwork object
+type CloudflareVirtualNetworkReconciler struct {
+	Client        client.Client
+	Scheme        *runtime.Scheme
+	ShardSelector labels.Selector // only the resources matching it are reconciled, all of them when nil
+	logger        *logr.Logger
+}
+
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflarevirtualnetworks,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflarevirtualnetworks/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cloudflare-tunnel-operator.beezlabs.app,resources=cloudflarevirtualnetworks/finalizers,verbs=update
+
+func (r *CloudflareVirtualNetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	lfc := log.FromContext(ctx)
+	r.logger = &lfc
+	lfc.Info("Reconciling...")
+
+	var virtualNetwork cfv1.CloudflareVirtualNetwork
+	if err := r.Client.Get(ctx, req.NamespacedName, &virtualNetwork); err != nil {
+		// the resource is gone once the finalizer has been removed, nothing more to do
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	lfc.V(1).Info("Resource fetched")
+
+	// the resource may have been relabelled into another shard while a requeue was pending
+	if !inShard(r.ShardSelector, &virtualNetwork) {
+		lfc.V(1).Info("Resource belongs to another shard, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	if !virtualNetwork.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizeVirtualNetwork(ctx, &virtualNetwork)
+	}
+
+	accountToken, accountTag, err := fetchCredentials(ctx, r.Client, r.logger, virtualNetwork.Namespace, virtualNetwork.Spec.TokenSecretName, cfv1.TokenSecretTokenKey, cfv1.TokenSecretAccountIDKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	cf, err := newCloudflareAPI(r.logger, accountToken, accountTag)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// the finalizer ensures that the routes are detached and the remote is cleaned up before the resource is gone
+	if !controllerutil.ContainsFinalizer(&virtualNetwork, constants.Finalizer) {
+		controllerutil.AddFinalizer(&virtualNetwork, constants.Finalizer)
+		if err := r.Client.Update(ctx, &virtualNetwork); err != nil {
+			lfc.Error(err, "could not add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.createVirtualNetworkRemote(ctx, cf, &virtualNetwork); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.Client.Status().Update(ctx, &virtualNetwork); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CloudflareVirtualNetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cfv1.CloudflareVirtualNetwork{}, builder.WithPredicates(shardPredicate(r.ShardSelector))).
+		Complete(r)
+}
+
+func (r *CloudflareVirtualNetworkReconciler) finalizeVirtualNetwork(ctx context.Context, virtualNetwork *cfv1.CloudflareVirtualNetwork) error {
+	if !controllerutil.ContainsFinalizer(virtualNetwork, constants.Finalizer) {
+		return nil
+	}
+
+	accountToken, accountTag, err := fetchCredentials(ctx, r.Client, r.logger, virtualNetwork.Namespace, virtualNetwork.Spec.TokenSecretName, cfv1.TokenSecretTokenKey, cfv1.TokenSecretAccountIDKey)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		// without credentials nothing can be cleaned up, which must not block the deletion, e.g. of the namespace
+		r.logger.Info("credentials secret is gone, skipping the cleanup of the remote virtual network")
+	} else {
+		cf, err := newCloudflareAPI(r.logger, accountToken, accountTag)
+		if err != nil {
+			return err
+		}
+		if err := r.deleteVirtualNetworkRemote(ctx, cf, virtualNetwork); err != nil {
+			return err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(virtualNetwork, constants.Finalizer)
+	if err := r.Client.Update(ctx, virtualNetwork); err != nil {
+		r.logger.Error(err, "could not remove finalizer")
+		return err
+	}
+	return nil
+}
+
+func (r *CloudflareVirtualNetworkReconciler) createVirtualNetworkRemote(ctx context.Context, cf *cloudflare.API, virtualNetwork *cfv1.CloudflareVirtualNetwork) error {
+	remoteName := virtualNetwork.Spec.RemoteName
+	if remoteName == "" {
+		remoteName = virtualNetwork.Namespace + "-" + virtualNetwork.Name
+	}
+
+	accountResourceContainer := cloudflare.AccountIdentifier(cf.AccountID)
+	falsePointer := false // needed as the struct below only accepts a *bool
+
+	// the id in the status is preferred so that renaming the virtual network doesn't create a new one
+	listParams := cloudflare.TunnelVirtualNetworksListParams{
+		Name:      remoteName,
+		IsDeleted: &falsePointer,
+	}
+	if virtualNetwork.Status.VirtualNetworkID != "" {
+		listParams = cloudflare.TunnelVirtualNetworksListParams{
+			ID:        virtualNetwork.Status.VirtualNetworkID,
+			IsDeleted: &falsePointer,
+		}
+	}
+	virtualNetworks, err := cf.ListTunnelVirtualNetworks(ctx, accountResourceContainer, listParams)
+	if err != nil {
+		r.logger.Error(err, "could not fetch virtual network list")
+		return err
+	}
+
+	if len(virtualNetworks) >= 2 {
+		err := fmt.Errorf("multiple virtual networks exist")
+		r.logger.Error(err, "2 or more virtual networks already exists with the given name. Unable to choose between one of them")
+		return err
+	} else if len(virtualNetworks) == 1 {
+		r.logger.V(1).Info("Virtual network exists, updating")
+		isDefault := virtualNetwork.Spec.IsDefault
+		if _, err := cf.UpdateTunnelVirtualNetwork(ctx, accountResourceContainer, cloudflare.TunnelVirtualNetworkUpdateParams{
+			VnetID:           virtualNetworks[0].ID,
+			Name:             remoteName,
+			Comment:          virtualNetwork.Spec.Comment,
+			IsDefaultNetwork: &isDefault,
+		}); err != nil {
+			r.logger.Error(err, "could not update virtual network")
+			return err
+		}
+		virtualNetwork.Status.VirtualNetworkID = virtualNetworks[0].ID
+	} else {
+		r.logger.Info("Virtual network doesn't exist. Creating...")
+		created, err := cf.CreateTunnelVirtualNetwork(ctx, accountResourceContainer, cloudflare.TunnelVirtualNetworkCreateParams{
+			Name:      remoteName,
+			Comment:   virtualNetwork.Spec.Comment,
+			IsDefault: virtualNetwork.Spec.IsDefault,
+		})
+		if err != nil {
+			r.logger.Error(err, "could not create virtual network")
+			return err
+		}
+		virtualNetwork.Status.VirtualNetworkID = created.ID
+	}
+	virtualNetwork.Status.RemoteName = remoteName
+	return nil
+}
+
+func (r *CloudflareVirtualNetworkReconciler) deleteVirtualNetworkRemote(ctx context.Context, cf *cloudflare.API, virtualNetwork *cfv1.CloudflareVirtualNetwork) error {
+	if virtualNetwork.Status.VirtualNetworkID == "" {
+		// never created in the remote
+		return nil
+	}
+
+	accountResourceContainer := cloudflare.AccountIdentifier(cf.AccountID)
+	falsePointer := false // needed as the struct below only accepts a *bool
+
+	// a virtual network cannot be deleted while routes are still attached to it
+	routes, err := cf.ListTunnelRoutes(ctx, accountResourceContainer, cloudflare.TunnelRoutesListParams{
+		VirtualNetworkID: virtualNetwork.Status.VirtualNetworkID,
+		IsDeleted:        &falsePointer,
+	})
+	if err != nil {
+		r.logger.Error(err, "could not fetch routes of virtual network")
+		return err
+	}
+	for _, route := range routes {
+		r.logger.Info("detaching route " + route.Network + " from virtual network")
+		if err := deleteTunnelRoute(cf, route.Network, virtualNetwork.Status.VirtualNetworkID); err != nil {
+			r.logger.Error(err, "could not delete tunnel route")
+			return err
+		}
+	}
+
+	virtualNetworks, err := cf.ListTunnelVirtualNetworks(ctx, accountResourceContainer, cloudflare.TunnelVirtualNetworksListParams{
+		ID:        virtualNetwork.Status.VirtualNetworkID,
+		IsDeleted: &falsePointer,
+	})
+	if err != nil {
+		r.logger.Error(err, "could not fetch virtual network list")
+		return err
+	}
+	if len(virtualNetworks) == 0 {
+		r.logger.V(1).Info("Virtual network already deleted")
+		return nil
+	}
+	r.logger.Info("deleting virtual network...")
+	if err := cf.DeleteTunnelVirtualNetwork(ctx, accountResourceContainer, virtualNetwork.Status.VirtualNetworkID); err != nil {
+		r.logger.Error(err, "could not delete virtual network")
+		return err
+	}
+	return nil
+}
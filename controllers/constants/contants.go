@@ -20,4 +20,54 @@ const (
 	OperatorName   = "cloudflare-tunnel-operator"
 	ResourceSuffix = "cf-tunnel"
 	CNAMESuffix    = ".cfargotunnel.com"
+	ManagedComment = "managed by " + OperatorName
+	Finalizer      = "cloudflare-tunnel-operator.beezlabs.app/finalizer"
+)
+
+const (
+	CredentialsTypeFile  = "file"  // tunnel credentials are mounted as a credentials-file JSON
+	CredentialsTypeToken = "token" // the raw tunnel token is passed to cloudflared via the environment
+	TunnelTokenKey       = "TUNNEL_TOKEN"
+)
+
+const (
+	ConfigDirectory   = "/config"
+	OriginCADirectory = ConfigDirectory + "/origin-ca"
+	OriginCAFile      = "ca.crt"
+	// ConfigHashAnnotation on the pods changes with the config and the credentials, so that cloudflared is restarted
+	ConfigHashAnnotation = "cloudflare-tunnel-operator.beezlabs.app/config-hash"
+)
+
+const (
+	ServiceTokenSecretSuffix    = "service-token"
+	ServiceTokenClientIDKey     = "clientID"
+	ServiceTokenClientSecretKey = "clientSecret"
+)
+
+const AccessAuthDomainSuffix = ".cloudflareaccess.com"
+
+const RunAsUser int64 = 65532 // the nonroot user of the cloudflared image
+
+const MetricsSuffix = "metrics"
+
+const (
+	MonitorKindServiceMonitor = "ServiceMonitor"
+	MonitorKindPodMonitor     = "PodMonitor"
+)
+
+const (
+	WorkloadKindDeployment = "Deployment"
+	WorkloadKindDaemonSet  = "DaemonSet"
+)
+
+const (
+	ConditionReady           = "Ready"
+	ReasonConnectorsReady    = "ConnectorsReady"
+	ReasonConnectorsNotReady = "ConnectorsNotReady"
+)
+
+const (
+	ConditionResolvedRefs = "ResolvedRefs"
+	ReasonResolvedRefs    = "ResolvedRefs"
+	ReasonRefNotPermitted = "RefNotPermitted"
 )
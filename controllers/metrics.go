@@ -0,0 +1,175 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsNamespace = "cloudflare_tunnel_operator"
+
+var (
+	cloudflareAPIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cloudflare_api_requests_total",
+		Help:      "Number of requests made to the Cloudflare API by endpoint, method and status code.",
+	}, []string{"endpoint", "method", "code"})
+	cloudflareAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "cloudflare_api_request_duration_seconds",
+		Help:      "Latency of the requests made to the Cloudflare API by endpoint and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "method"})
+	cloudflareAPIRateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "cloudflare_api_rate_limited_total",
+		Help:      "Number of requests to the Cloudflare API that were rate limited by endpoint.",
+	}, []string{"endpoint"})
+	reconcilePhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "reconcile_phase_duration_seconds",
+		Help:      "Duration of the phases of a reconcile by controller and phase.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"controller", "phase"})
+	tunnelConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "tunnel_connections",
+		Help:      "Number of active connections of a tunnel to the Cloudflare edge.",
+	}, []string{"namespace", "name"})
+	tunnelConnectors = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "tunnel_connectors",
+		Help:      "Number of connectors of a tunnel by cloudflared version.",
+	}, []string{"namespace", "name", "version"})
+)
+
+// tunnelConnectorVersions remembers the versions reported per tunnel, so that versions no longer in use can be removed
+var (
+	tunnelConnectorVersions      = map[types.NamespacedName][]string{}
+	tunnelConnectorVersionsMutex sync.Mutex
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		cloudflareAPIRequests,
+		cloudflareAPIDuration,
+		cloudflareAPIRateLimited,
+		reconcilePhaseDuration,
+		tunnelConnections,
+		tunnelConnectors,
+	)
+}
+
+// metricsTransport records the requests made by the cloudflare sdk
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := apiEndpoint(req.URL.Path)
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	cloudflareAPIDuration.WithLabelValues(endpoint, req.Method).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			cloudflareAPIRateLimited.WithLabelValues(endpoint).Inc()
+		}
+	}
+	cloudflareAPIRequests.WithLabelValues(endpoint, req.Method, code).Inc()
+	return resp, err
+}
+
+// apiEndpoint replaces the ids in the path of a request, e.g. account and tunnel ids, to keep the cardinality low
+func apiEndpoint(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/client/v4"), "/")
+	for i, segment := range segments {
+		if strings.IndexFunc(segment, unicode.IsDigit) != -1 {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// phaseTimer records the duration of the phases of a single reconcile
+type phaseTimer struct {
+	controller string
+	phase      string
+	start      time.Time
+}
+
+func newPhaseTimer(controller string) *phaseTimer {
+	return &phaseTimer{controller: controller}
+}
+
+// next ends the current phase and starts the given one
+func (t *phaseTimer) next(phase string) {
+	t.stop()
+	t.phase = phase
+	t.start = time.Now()
+}
+
+// stop ends the current phase, it is meant to be deferred so that a phase failing early is recorded as well
+func (t *phaseTimer) stop() {
+	if t.phase == "" {
+		return
+	}
+	reconcilePhaseDuration.WithLabelValues(t.controller, t.phase).Observe(time.Since(t.start).Seconds())
+	t.phase = ""
+}
+
+// setTunnelMetrics updates the gauges of the given tunnel with the connectors as reported by the remote
+func setTunnelMetrics(name types.NamespacedName, connections int, connectorVersions map[string]int) {
+	tunnelConnections.WithLabelValues(name.Namespace, name.Name).Set(float64(connections))
+
+	tunnelConnectorVersionsMutex.Lock()
+	defer tunnelConnectorVersionsMutex.Unlock()
+	for _, version := range tunnelConnectorVersions[name] {
+		if _, ok := connectorVersions[version]; !ok {
+			tunnelConnectors.DeleteLabelValues(name.Namespace, name.Name, version)
+		}
+	}
+	versions := make([]string, 0, len(connectorVersions))
+	for version, count := range connectorVersions {
+		tunnelConnectors.WithLabelValues(name.Namespace, name.Name, version).Set(float64(count))
+		versions = append(versions, version)
+	}
+	tunnelConnectorVersions[name] = versions
+}
+
+// deleteTunnelMetrics removes the gauges of a tunnel that no longer exists
+func deleteTunnelMetrics(name types.NamespacedName) {
+	tunnelConnections.DeleteLabelValues(name.Namespace, name.Name)
+
+	tunnelConnectorVersionsMutex.Lock()
+	defer tunnelConnectorVersionsMutex.Unlock()
+	for _, version := range tunnelConnectorVersions[name] {
+		tunnelConnectors.DeleteLabelValues(name.Namespace, name.Name, version)
+	}
+	delete(tunnelConnectorVersions, name)
+}
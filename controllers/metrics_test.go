@@ -0,0 +1,55 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import "testing"
+
+func TestAPIEndpoint(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "ids of the account and tunnel",
+			path: "/client/v4/accounts/023e105f4ecef8ad9ca31a8372d0c353/cfd_tunnel/f70ff985-a4ef-4643-bbbc-4a0ed4fc8415/token",
+			want: "/accounts/:id/cfd_tunnel/:id/token",
+		},
+		{
+			name: "without ids",
+			path: "/client/v4/zones",
+			want: "/zones",
+		},
+		{
+			name: "network of a route",
+			path: "/client/v4/accounts/023e105f4ecef8ad9ca31a8372d0c353/teamnet/routes/network/10.0.0.0%2F8",
+			want: "/accounts/:id/teamnet/routes/network/:id",
+		},
+		{
+			name: "path without the api prefix",
+			path: "/user/tokens/verify",
+			want: "/user/tokens/verify",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiEndpoint(tt.path); got != tt.want {
+				t.Errorf("apiEndpoint(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -18,21 +18,34 @@ package models
 
 import (
 	"bytes"
+	"strings"
 	"text/template"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
 	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
 	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/templates"
 )
 
 type ConfigMapModel struct {
-	Name      string
-	Namespace string
-	Service   string
-	TunnelID  string
-	Domain    string
+	Name            string
+	Namespace       string
+	Ingress         []IngressRule
+	TunnelID        string
+	CredentialsType string
+	WarpRouting     bool
+	// OriginRequest contains the tunnel-level originRequest defaults
+	OriginRequest *cfv1beta1.CloudflareTunnelOriginRequest
+}
+
+type IngressRule struct {
+	Hostname string
+	Service  string // the URL of the service
+	// OriginRequest contains the originRequest of the rule
+	OriginRequest *cfv1beta1.CloudflareTunnelOriginRequest
 }
 
 func ConfigMap(model ConfigMapModel) *ConfigMapModel {
@@ -61,7 +74,29 @@ func (cm *ConfigMapModel) GetConfigMap() (*corev1.ConfigMap, error) {
 }
 
 func (cm *ConfigMapModel) generateConfigMap() (string, error) {
-	templateEngine, err := template.New("config").Parse(templates.CONFIG)
+	ingress := make([]IngressRule, 0, len(cm.Ingress)+1)
+	for _, rule := range cm.Ingress {
+		// the origin server name of a rule defaults to its hostname unless the rule or the tunnel sets one
+		// a wildcard is no valid server name, so those rules keep the default of cloudflared
+		if rule.Hostname != "" && !strings.HasPrefix(rule.Hostname, "*") && !cm.setsOriginServerName() {
+			originRequest := &cfv1beta1.CloudflareTunnelOriginRequest{}
+			if rule.OriginRequest != nil {
+				originRequest = rule.OriginRequest.DeepCopy()
+			}
+			if originRequest.OriginServerName == "" {
+				originRequest.OriginServerName = rule.Hostname
+			}
+			rule.OriginRequest = originRequest
+		}
+		ingress = append(ingress, rule)
+	}
+	// cloudflared requires the last rule to match everything
+	if last := len(ingress) - 1; last < 0 || ingress[last].Hostname != "" {
+		ingress = append(ingress, IngressRule{Service: cfv1beta1.ProtocolHTTPStatus + ":404"})
+	}
+	cm.Ingress = ingress
+
+	templateEngine, err := template.New("config").Funcs(templateFuncs).Parse(templates.CONFIG)
 	if err != nil {
 		return "", err
 	}
@@ -76,3 +111,28 @@ func (cm *ConfigMapModel) generateConfigMap() (string, error) {
 
 	return secret, nil
 }
+
+// setsOriginServerName checks if the tunnel-level originRequest sets the origin server name of every rule
+func (cm *ConfigMapModel) setsOriginServerName() bool {
+	return cm.OriginRequest != nil && cm.OriginRequest.OriginServerName != ""
+}
+
+var templateFuncs = template.FuncMap{
+	"toYaml": toYaml,
+	"indent": indent,
+}
+
+// toYaml renders the given value as YAML, relying on the json tags of the API types
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// indent prefixes every line of text with the given number of spaces
+func indent(spaces int, text string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.ReplaceAll(text, "\n", "\n"+padding)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+// cloudflaredConfig is the part of the config file of cloudflared that is generated
+type cloudflaredConfig struct {
+	Tunnel          string                                   `json:"tunnel"`
+	CredentialsFile string                                   `json:"credentials-file"`
+	OriginRequest   *cfv1beta1.CloudflareTunnelOriginRequest `json:"originRequest"`
+	WarpRouting     cloudflaredWarpRouting                   `json:"warp-routing"`
+	Ingress         []cloudflaredIngressRule                 `json:"ingress"`
+}
+
+type cloudflaredWarpRouting struct {
+	Enabled bool `json:"enabled"`
+}
+
+type cloudflaredIngressRule struct {
+	Hostname      string                                   `json:"hostname"`
+	Service       string                                   `json:"service"`
+	OriginRequest *cfv1beta1.CloudflareTunnelOriginRequest `json:"originRequest"`
+}
+
+func TestConfigMap(t *testing.T) {
+	const tunnelID = "f70ff985-a4ef-4643-bbbc-4a0ed4fc8415"
+	trueValue := true
+	tests := []struct {
+		name  string
+		model ConfigMapModel
+		want  cloudflaredConfig
+	}{
+		{
+			name: "catch-all rule is added",
+			model: ConfigMapModel{
+				TunnelID:        tunnelID,
+				CredentialsType: constants.CredentialsTypeFile,
+				Ingress:         []IngressRule{{Hostname: "app.example.com", Service: "http://app.default:80"}},
+			},
+			want: cloudflaredConfig{
+				Tunnel:          tunnelID,
+				CredentialsFile: "/config/" + tunnelID + ".json",
+				Ingress: []cloudflaredIngressRule{
+					{
+						Hostname:      "app.example.com",
+						Service:       "http://app.default:80",
+						OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{OriginServerName: "app.example.com"},
+					},
+					{Service: "http_status:404"},
+				},
+			},
+		},
+		{
+			name: "catch-all rule of the spec is kept",
+			model: ConfigMapModel{
+				TunnelID:        tunnelID,
+				CredentialsType: constants.CredentialsTypeToken,
+				Ingress: []IngressRule{
+					{Hostname: "*.example.com", Service: "http://app.default:80"},
+					{Service: "hello_world"},
+				},
+			},
+			want: cloudflaredConfig{
+				Tunnel: tunnelID,
+				Ingress: []cloudflaredIngressRule{
+					{Hostname: "*.example.com", Service: "http://app.default:80"},
+					{Service: "hello_world"},
+				},
+			},
+		},
+		{
+			name: "originRequest of the tunnel and the rules",
+			model: ConfigMapModel{
+				TunnelID:        tunnelID,
+				CredentialsType: constants.CredentialsTypeToken,
+				OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{
+					ConnectTimeout: &metav1.Duration{Duration: 10 * time.Second},
+					NoTLSVerify:    &trueValue,
+				},
+				Ingress: []IngressRule{
+					{
+						Hostname: "app.example.com",
+						Service:  "https://app.default:443",
+						OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{
+							OriginServerName: "origin.example.com",
+							HTTPHostHeader:   "app.internal",
+						},
+					},
+				},
+			},
+			want: cloudflaredConfig{
+				Tunnel: tunnelID,
+				OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{
+					ConnectTimeout: &metav1.Duration{Duration: 10 * time.Second},
+					NoTLSVerify:    &trueValue,
+				},
+				Ingress: []cloudflaredIngressRule{
+					{
+						Hostname: "app.example.com",
+						Service:  "https://app.default:443",
+						OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{
+							OriginServerName: "origin.example.com",
+							HTTPHostHeader:   "app.internal",
+						},
+					},
+					{Service: "http_status:404"},
+				},
+			},
+		},
+		{
+			name: "originServerName of the tunnel",
+			model: ConfigMapModel{
+				TunnelID:        tunnelID,
+				CredentialsType: constants.CredentialsTypeToken,
+				OriginRequest:   &cfv1beta1.CloudflareTunnelOriginRequest{OriginServerName: "origin.example.com"},
+				Ingress: []IngressRule{
+					{Hostname: "app.example.com", Service: "https://app.default:443"},
+					{
+						Hostname:      "api.example.com",
+						Service:       "https://api.default:443",
+						OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{HTTPHostHeader: "api.internal"},
+					},
+				},
+			},
+			want: cloudflaredConfig{
+				Tunnel:        tunnelID,
+				OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{OriginServerName: "origin.example.com"},
+				Ingress: []cloudflaredIngressRule{
+					{Hostname: "app.example.com", Service: "https://app.default:443"},
+					{
+						Hostname:      "api.example.com",
+						Service:       "https://api.default:443",
+						OriginRequest: &cfv1beta1.CloudflareTunnelOriginRequest{HTTPHostHeader: "api.internal"},
+					},
+					{Service: "http_status:404"},
+				},
+			},
+		},
+		{
+			name: "warp-routing",
+			model: ConfigMapModel{
+				TunnelID:        tunnelID,
+				CredentialsType: constants.CredentialsTypeToken,
+				WarpRouting:     true,
+			},
+			want: cloudflaredConfig{
+				Tunnel:      tunnelID,
+				WarpRouting: cloudflaredWarpRouting{Enabled: true},
+				Ingress:     []cloudflaredIngressRule{{Service: "http_status:404"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configMap, err := ConfigMap(tt.model).GetConfigMap()
+			if err != nil {
+				t.Fatalf("GetConfigMap() failed: %v", err)
+			}
+			var got cloudflaredConfig
+			if err := yaml.UnmarshalStrict([]byte(configMap.Data["config.yaml"]), &got); err != nil {
+				t.Fatalf("could not parse the config:\n%s\n%v", configMap.Data["config.yaml"], err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("config.yaml = %+v, want %+v\n%s", got, tt.want, configMap.Data["config.yaml"])
+			}
+		})
+	}
+}
@@ -17,22 +17,38 @@ limitations under the License.
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
 	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
 )
 
 type DeploymentModel struct {
 	Name            string
 	Namespace       string
-	Replicas        int32
+	Replicas        *int32 // nil when the replicas are managed by an autoscaler
 	TunnelID        string
 	Image           string
 	ImagePullPolicy corev1.PullPolicy
 	Command         []string
 	Args            []string
+	CredentialsType string
+	OriginCA        *cfv1beta1.CloudflareTunnelOriginCA
+	SocketServices  []*cfv1beta1.CloudflareTunnelService
+	PodTemplate     *cfv1beta1.CloudflareTunnelPodTemplate
+	Probes          *cfv1beta1.CloudflareTunnelProbes
+	ServesMetrics   bool // the probes are left out when cloudflared does not serve /ready on the metrics port
+	SpreadPods      bool
 	Secret          *corev1.Secret
 	ConfigMap       *corev1.ConfigMap
 }
@@ -42,94 +58,309 @@ func Deployment(model DeploymentModel) *DeploymentModel {
 }
 
 func (d *DeploymentModel) GetDeployment() *appsv1.Deployment {
-	image := "cloudflare/cloudflared:latest"
-	if d.Image != "" {
-		image = d.Image
+	return &appsv1.Deployment{
+		ObjectMeta: d.objectMeta(),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: d.Replicas,
+			Selector: d.selector(),
+			Template: d.podTemplate(),
+		},
 	}
-	imagePullPolicy := corev1.PullAlways
-	if d.ImagePullPolicy != "" {
-		imagePullPolicy = d.ImagePullPolicy
+}
+
+// GetDaemonSet runs the same pods as GetDeployment, but with a connector on every node matching the pod template
+func (d *DeploymentModel) GetDaemonSet() *appsv1.DaemonSet {
+	return &appsv1.DaemonSet{
+		ObjectMeta: d.objectMeta(),
+		Spec: appsv1.DaemonSetSpec{
+			Selector: d.selector(),
+			Template: d.podTemplate(),
+		},
 	}
-	command := []string{"cloudflared"}
-	if len(d.Command) != 0 {
-		command = d.Command
+}
+
+func (d *DeploymentModel) objectMeta() metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      d.Name + "-" + constants.ResourceSuffix,
+		Namespace: d.Namespace,
+		Labels: map[string]string{
+			"app.kubernetes.io/name":       d.Name,
+			"app.kubernetes.io/component":  "controller",
+			"app.kubernetes.io/created-by": constants.OperatorName,
+		},
 	}
-	args := []string{"tunnel", "--metrics", "localhost:9090", "--no-autoupdate", "--config", "/config/config.yaml", "run"}
-	if len(d.Args) != 0 {
-		args = d.Args
+}
+
+func (d *DeploymentModel) selector() *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{
+			"app.kubernetes.io/name": d.Name,
+		},
 	}
-	return &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      d.Name + "-" + constants.ResourceSuffix,
-			Namespace: d.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/name":       d.Name,
-				"app.kubernetes.io/component":  "controller",
-				"app.kubernetes.io/created-by": constants.OperatorName,
+}
+
+func (d *DeploymentModel) podTemplate() corev1.PodTemplateSpec {
+	// the config, the credentials and the CA share a single projected volume instead of subPath mounts, which would
+	// never see an update of their source
+	configSources := []corev1.VolumeProjection{
+		{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: d.Name + "-" + constants.ResourceSuffix},
 			},
 		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &d.Replicas,
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app.kubernetes.io/name": d.Name,
+	}
+	var env []corev1.EnvVar
+	if d.CredentialsType == constants.CredentialsTypeToken {
+		// `cloudflared tunnel run` picks up the token from the TUNNEL_TOKEN environment variable
+		env = append(env, corev1.EnvVar{
+			Name: constants.TunnelTokenKey,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: d.Name + "-" + constants.ResourceSuffix},
+					Key:                  constants.TunnelTokenKey,
 				},
 			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app.kubernetes.io/name": d.Name,
-					},
+		})
+	} else {
+		configSources = append(configSources, corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: d.Name + "-" + constants.ResourceSuffix},
+				Items:                []corev1.KeyToPath{{Key: d.TunnelID + ".json", Path: d.TunnelID + ".json"}},
+			},
+		})
+	}
+	if d.OriginCA != nil {
+		// the referenced key is always projected as the same file so that the caPool path stays stable
+		items := []corev1.KeyToPath{{Key: d.OriginCA.Key, Path: path.Join(path.Base(constants.OriginCADirectory), constants.OriginCAFile)}}
+		if d.OriginCA.SecretName != "" {
+			configSources = append(configSources, corev1.VolumeProjection{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: d.OriginCA.SecretName},
+					Items:                items,
 				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
+			})
+		} else {
+			configSources = append(configSources, corev1.VolumeProjection{
+				ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: d.OriginCA.ConfigMapName},
+					Items:                items,
+				},
+			})
+		}
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      "cloudflared-config",
+			MountPath: constants.ConfigDirectory,
+			ReadOnly:  true,
+		},
+	}
+	volumes := []corev1.Volume{
+		{
+			Name: "cloudflared-config",
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{Sources: configSources},
+			},
+		},
+	}
+	for i, service := range d.SocketServices {
+		if service == nil || service.Protocol != cfv1beta1.ProtocolUnix || service.SocketVolume == nil {
+			continue
+		}
+		// the whole directory of the socket is mounted, since the socket itself may be recreated by the origin
+		socketVolume := corev1.Volume{Name: "cloudflared-socket-" + strconv.Itoa(i)}
+		if service.SocketVolume.HostPath != "" {
+			socketVolume.VolumeSource = corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: service.SocketVolume.HostPath},
+			}
+		} else {
+			socketVolume.VolumeSource = corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: service.SocketVolume.ClaimName},
+			}
+		}
+		volumes = append(volumes, socketVolume)
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      socketVolume.Name,
+			MountPath: path.Dir(service.Path),
+		})
+	}
+	// the defaults satisfy the restricted profile of the Pod Security Standards
+	trueValue := true
+	falseValue := false
+	runAsUser := constants.RunAsUser
+	podSecurityContext := &corev1.PodSecurityContext{
+		RunAsNonRoot:   &trueValue,
+		RunAsUser:      &runAsUser,
+		RunAsGroup:     &runAsUser,
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	containerSecurityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &falseValue,
+		ReadOnlyRootFilesystem:   &trueValue,
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+	var readinessThresholds, livenessThresholds *cfv1beta1.CloudflareTunnelProbe
+	if d.Probes != nil {
+		readinessThresholds = d.Probes.Readiness
+		livenessThresholds = d.Probes.Liveness
+	}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app.kubernetes.io/name": d.Name,
+			},
+			Annotations: map[string]string{
+				constants.ConfigHashAnnotation: d.configHash(),
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:            "cloudflared",
+					Image:           d.Image,
+					ImagePullPolicy: d.ImagePullPolicy,
+					Command:         d.Command,
+					Args:            d.Args,
+					Ports: []corev1.ContainerPort{
 						{
-							Name:            "cloudflared",
-							Image:           image,
-							ImagePullPolicy: imagePullPolicy,
-							Command:         command,
-							Args:            args,
-							Ports: []corev1.ContainerPort{
-								{
-									Name:          "metrics",
-									ContainerPort: 9090,
-									Protocol:      corev1.ProtocolTCP,
-								},
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "cloudflared-config",
-									MountPath: "/config/config.yaml",
-									SubPath:   "config.yaml",
-								},
-								{
-									Name:      "cloudflared-creds",
-									MountPath: "/config/" + d.TunnelID + ".json",
-									SubPath:   d.TunnelID + ".json",
-								},
-							},
+							Name:          "metrics",
+							ContainerPort: cfv1beta1.MetricsPort,
+							Protocol:      corev1.ProtocolTCP,
 						},
 					},
-					Volumes: []corev1.Volume{
-						{
-							Name: "cloudflared-config",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{Name: d.Name + "-" + constants.ResourceSuffix},
-								},
-							},
-						},
-						{
-							Name: "cloudflared-creds",
-							VolumeSource: corev1.VolumeSource{
-								Secret: &corev1.SecretVolumeSource{
-									SecretName: d.Name + "-" + constants.ResourceSuffix,
-								},
-							},
-						},
+					Env:             env,
+					VolumeMounts:    volumeMounts,
+					SecurityContext: containerSecurityContext,
+				},
+			},
+			Volumes:                      volumes,
+			SecurityContext:              podSecurityContext,
+			AutomountServiceAccountToken: &falseValue,
+		},
+	}
+	if d.ServesMetrics {
+		template.Spec.Containers[0].ReadinessProbe = probe(readinessThresholds)
+		template.Spec.Containers[0].LivenessProbe = probe(livenessThresholds)
+	}
+	if d.SpreadPods {
+		d.applySpreading(&template)
+	}
+	d.applyPodTemplate(&template)
+	return template
+}
+
+// applySpreading prefers placing the pods on different nodes and zones, so that a single drain or outage does not
+// take down all connectors of the tunnel
+func (d *DeploymentModel) applySpreading(template *corev1.PodTemplateSpec) {
+	selector := d.selector()
+	template.Spec.Affinity = &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: selector,
+						TopologyKey:   corev1.LabelHostname,
 					},
 				},
 			},
 		},
 	}
+	template.Spec.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       corev1.LabelTopologyZone,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     selector,
+		},
+	}
+}
+
+// applyPodTemplate merges the overrides of the spec into the generated pod template
+func (d *DeploymentModel) applyPodTemplate(template *corev1.PodTemplateSpec) {
+	if d.PodTemplate == nil {
+		return
+	}
+	for key, value := range d.PodTemplate.Annotations {
+		// the hash of the config must not be pinned, otherwise changes of the config would not restart cloudflared
+		if _, ok := template.Annotations[key]; !ok {
+			template.Annotations[key] = value
+		}
+	}
+	for key, value := range d.PodTemplate.Labels {
+		// the selector of the deployment depends on the labels set by the operator
+		if _, ok := template.Labels[key]; !ok {
+			template.Labels[key] = value
+		}
+	}
+	template.Spec.Containers[0].Resources = d.PodTemplate.Resources
+	template.Spec.NodeSelector = d.PodTemplate.NodeSelector
+	template.Spec.Tolerations = d.PodTemplate.Tolerations
+	if d.PodTemplate.Affinity != nil {
+		template.Spec.Affinity = d.PodTemplate.Affinity
+	}
+	if len(d.PodTemplate.TopologySpreadConstraints) != 0 {
+		template.Spec.TopologySpreadConstraints = d.PodTemplate.TopologySpreadConstraints
+	}
+	template.Spec.PriorityClassName = d.PodTemplate.PriorityClassName
+	template.Spec.ServiceAccountName = d.PodTemplate.ServiceAccountName
+	template.Spec.ImagePullSecrets = d.PodTemplate.ImagePullSecrets
+	if d.PodTemplate.SecurityContext != nil {
+		template.Spec.SecurityContext = d.PodTemplate.SecurityContext
+	}
+	if d.PodTemplate.ContainerSecurityContext != nil {
+		template.Spec.Containers[0].SecurityContext = d.PodTemplate.ContainerSecurityContext
+	}
+	if d.PodTemplate.AutomountServiceAccountToken != nil {
+		template.Spec.AutomountServiceAccountToken = d.PodTemplate.AutomountServiceAccountToken
+	}
+}
+
+// configHash hashes the config and the credentials mounted into the pods, which cloudflared only reads on startup
+func (d *DeploymentModel) configHash() string {
+	hash := sha256.New()
+	if d.ConfigMap != nil {
+		writeSorted(hash, d.ConfigMap.Data)
+	}
+	if d.Secret != nil {
+		writeSorted(hash, d.Secret.StringData)
+		data := make(map[string]string, len(d.Secret.Data))
+		for key, value := range d.Secret.Data {
+			data[key] = string(value)
+		}
+		writeSorted(hash, data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeSorted writes the entries of the map in the order of their keys, so that the hash is stable
+func writeSorted(w io.Writer, data map[string]string) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, _ = w.Write([]byte(key + "\x00" + data[key] + "\x00"))
+	}
+}
+
+// probe creates a probe on the /ready endpoint of cloudflared, which succeeds once a connection to the edge is up
+func probe(thresholds *cfv1beta1.CloudflareTunnelProbe) *corev1.Probe {
+	probe := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/ready",
+				Port: intstr.FromString("metrics"),
+			},
+		},
+	}
+	if thresholds != nil {
+		probe.InitialDelaySeconds = thresholds.InitialDelaySeconds
+		probe.PeriodSeconds = thresholds.PeriodSeconds
+		probe.TimeoutSeconds = thresholds.TimeoutSeconds
+		probe.FailureThreshold = thresholds.FailureThreshold
+		probe.SuccessThreshold = thresholds.SuccessThreshold
+	}
+	return probe
 }
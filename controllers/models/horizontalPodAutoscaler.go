@@ -0,0 +1,80 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+type HorizontalPodAutoscalerModel struct {
+	Name        string
+	Namespace   string
+	Autoscaling *cfv1beta1.CloudflareTunnelAutoscaling
+}
+
+func HorizontalPodAutoscaler(model HorizontalPodAutoscalerModel) *HorizontalPodAutoscalerModel {
+	return &model
+}
+
+func (h *HorizontalPodAutoscalerModel) GetHorizontalPodAutoscaler() *autoscalingv2.HorizontalPodAutoscaler {
+	// without explicit metrics the connectors are scaled on their cpu utilization
+	metrics := h.Autoscaling.Metrics
+	if len(metrics) == 0 {
+		targetCPU := int32(80)
+		if h.Autoscaling.TargetCPUUtilizationPercentage != nil {
+			targetCPU = *h.Autoscaling.TargetCPUUtilizationPercentage
+		}
+		metrics = []autoscalingv2.MetricSpec{
+			{
+				Type: autoscalingv2.ResourceMetricSourceType,
+				Resource: &autoscalingv2.ResourceMetricSource{
+					Name: corev1.ResourceCPU,
+					Target: autoscalingv2.MetricTarget{
+						Type:               autoscalingv2.UtilizationMetricType,
+						AverageUtilization: &targetCPU,
+					},
+				},
+			},
+		}
+	}
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.Name + "-" + constants.ResourceSuffix,
+			Namespace: h.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       h.Name,
+				"app.kubernetes.io/component":  "controller",
+				"app.kubernetes.io/created-by": constants.OperatorName,
+			},
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       h.Name + "-" + constants.ResourceSuffix,
+			},
+			MinReplicas: h.Autoscaling.MinReplicas,
+			MaxReplicas: h.Autoscaling.MaxReplicas,
+			Metrics:     metrics,
+		},
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	cfv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+// MonitorModel generates a ServiceMonitor or PodMonitor of the Prometheus Operator
+// the objects are unstructured so that the operator does not depend on the Prometheus Operator being installed
+type MonitorModel struct {
+	Name       string
+	Namespace  string
+	TunnelID   string
+	Monitoring *cfv1beta1.CloudflareTunnelMonitoring
+}
+
+func Monitor(model MonitorModel) *MonitorModel {
+	return &model
+}
+
+// MonitorGroupVersionKind returns the kind of the monitor for the given monitoring spec
+func MonitorGroupVersionKind(kind string) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: kind}
+}
+
+func (m *MonitorModel) GetMonitor() (*unstructured.Unstructured, error) {
+	// the tunnel is attached to every scraped series so that the connectors of different tunnels can be told apart
+	relabelings := []cfv1beta1.CloudflareTunnelRelabelConfig{
+		{Action: "replace", TargetLabel: "tunnel", Replacement: m.Name},
+		{Action: "replace", TargetLabel: "tunnel_id", Replacement: m.TunnelID},
+	}
+	relabelings = append(relabelings, m.Monitoring.Relabelings...)
+	relabelingsJSON, err := json.Marshal(relabelings)
+	if err != nil {
+		return nil, err
+	}
+	var relabelingsUnstructured []interface{}
+	if err := json.Unmarshal(relabelingsJSON, &relabelingsUnstructured); err != nil {
+		return nil, err
+	}
+
+	endpoint := map[string]interface{}{
+		"port":        "metrics",
+		"path":        "/metrics",
+		"relabelings": relabelingsUnstructured,
+	}
+	if m.Monitoring.Interval != "" {
+		endpoint["interval"] = m.Monitoring.Interval
+	}
+
+	endpointsKey := "podMetricsEndpoints"
+	matchLabels := map[string]interface{}{
+		"app.kubernetes.io/name": m.Name,
+	}
+	if m.Monitoring.Kind == constants.MonitorKindServiceMonitor {
+		// a service monitor selects the metrics service instead of the pods
+		endpointsKey = "endpoints"
+		matchLabels["app.kubernetes.io/component"] = constants.MetricsSuffix
+	}
+
+	labels := map[string]interface{}{}
+	for key, value := range m.Monitoring.Labels {
+		labels[key] = value
+	}
+	labels["app.kubernetes.io/name"] = m.Name
+	labels["app.kubernetes.io/component"] = constants.MetricsSuffix
+	labels["app.kubernetes.io/created-by"] = constants.OperatorName
+
+	monitor := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":      m.Name + "-" + constants.ResourceSuffix,
+				"namespace": m.Namespace,
+				"labels":    labels,
+			},
+			"spec": map[string]interface{}{
+				"selector": map[string]interface{}{
+					"matchLabels": matchLabels,
+				},
+				endpointsKey: []interface{}{endpoint},
+			},
+		},
+	}
+	monitor.SetGroupVersionKind(MonitorGroupVersionKind(m.Monitoring.Kind))
+	return monitor, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+type PodDisruptionBudgetModel struct {
+	Name           string
+	Namespace      string
+	MaxUnavailable *intstr.IntOrString
+}
+
+func PodDisruptionBudget(model PodDisruptionBudgetModel) *PodDisruptionBudgetModel {
+	return &model
+}
+
+func (p *PodDisruptionBudgetModel) GetPodDisruptionBudget() *policyv1.PodDisruptionBudget {
+	maxUnavailable := intstr.FromInt(1)
+	if p.MaxUnavailable != nil {
+		maxUnavailable = *p.MaxUnavailable
+	}
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.Name + "-" + constants.ResourceSuffix,
+			Namespace: p.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       p.Name,
+				"app.kubernetes.io/component":  "controller",
+				"app.kubernetes.io/created-by": constants.OperatorName,
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app.kubernetes.io/name": p.Name,
+				},
+			},
+		},
+	}
+}
@@ -18,6 +18,7 @@ package models
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"text/template"
 
@@ -29,12 +30,13 @@ import (
 )
 
 type SecretModel struct {
-	Name         string
-	Namespace    string
-	TunnelToken  string
-	AccountTag   string
-	TunnelSecret string
-	TunnelID     string
+	Name            string
+	Namespace       string
+	CredentialsType string
+	TunnelToken     string
+	AccountTag      string
+	TunnelSecret    string
+	TunnelID        string
 }
 
 type tunnelToken struct {
@@ -48,9 +50,16 @@ func Secret(model SecretModel) *SecretModel {
 }
 
 func (s *SecretModel) GetSecret() (*corev1.Secret, error) {
-	secret, err := s.generateSecret()
-	if err != nil {
-		return nil, err
+	stringData := map[string]string{}
+	if s.CredentialsType == constants.CredentialsTypeToken {
+		// cloudflared reads the raw token directly, so no credentials file is needed
+		stringData[constants.TunnelTokenKey] = s.TunnelToken
+	} else {
+		secret, err := s.generateSecret()
+		if err != nil {
+			return nil, err
+		}
+		stringData[s.TunnelID+".json"] = secret
 	}
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
@@ -62,16 +71,18 @@ func (s *SecretModel) GetSecret() (*corev1.Secret, error) {
 				"app.kubernetes.io/created-by": constants.OperatorName,
 			},
 		},
-		StringData: map[string]string{
-			s.TunnelID + ".json": secret,
-		},
-		Type: corev1.SecretTypeOpaque,
+		StringData: stringData,
+		Type:       corev1.SecretTypeOpaque,
 	}, nil
 }
 
 func (s *SecretModel) generateSecret() (string, error) {
+	tokenDecodedBytes, err := base64.StdEncoding.DecodeString(s.TunnelToken)
+	if err != nil {
+		return "", err
+	}
 	var tokenJson tunnelToken
-	if err := json.Unmarshal([]byte(s.TunnelToken), &tokenJson); err != nil {
+	if err := json.Unmarshal(tokenDecodedBytes, &tokenJson); err != nil {
 		return "", err
 	}
 	s.AccountTag = tokenJson.A
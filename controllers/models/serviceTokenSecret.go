@@ -0,0 +1,55 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package models
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers/constants"
+)
+
+type ServiceTokenSecretModel struct {
+	Name         string // name of the AccessServiceToken
+	SecretName   string
+	Namespace    string
+	ClientID     string
+	ClientSecret string
+}
+
+func ServiceTokenSecret(model ServiceTokenSecretModel) *ServiceTokenSecretModel {
+	return &model
+}
+
+func (s *ServiceTokenSecretModel) GetSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s.SecretName,
+			Namespace: s.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       s.Name,
+				"app.kubernetes.io/component":  "access-service-token",
+				"app.kubernetes.io/created-by": constants.OperatorName,
+			},
+		},
+		StringData: map[string]string{
+			constants.ServiceTokenClientIDKey:     s.ClientID,
+			constants.ServiceTokenClientSecretKey: s.ClientSecret,
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2022 Beez Innovation Labs.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// inShard checks if the object belongs to the shard of the manager, every object does when no selector is set
+func inShard(selector labels.Selector, object client.Object) bool {
+	return selector == nil || selector.Matches(labels.Set(object.GetLabels()))
+}
+
+// shardPredicate filters out the events of objects that are reconciled by another shard
+func shardPredicate(selector labels.Selector) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(object client.Object) bool {
+		return inShard(selector, object)
+	})
+}
@@ -31,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cloudflaretunneloperatorv1alpha1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	cloudflaretunneloperatorv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -66,6 +67,8 @@ var _ = BeforeSuite(func() {
 
 	err = cloudflaretunneloperatorv1alpha1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = cloudflaretunneloperatorv1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:scheme
 
@@ -18,11 +18,22 @@ package templates
 
 const CONFIG = `
 tunnel: {{ .TunnelID }}
+{{- if ne .CredentialsType "token" }}
 credentials-file: /config/{{ .TunnelID }}.json
+{{- end }}
+{{- with .OriginRequest }}
+originRequest:
+{{ toYaml . | indent 2 }}
+{{- end }}
 warp-routing:
-  enabled: true
+  enabled: {{ .WarpRouting }}
 ingress:
-  - service: {{ .Service }}
+{{- range .Ingress }}
+  - {{ with .Hostname }}hostname: {{ printf "%q" . }}
+    {{ end }}service: {{ .Service }}
+{{- with .OriginRequest }}
     originRequest:
-      originServerName: {{ .Domain }}
+{{ toYaml . | indent 6 }}
+{{- end }}
+{{- end }}
 `
@@ -17,21 +17,27 @@ limitations under the License.
 package main
 
 import (
+	"crypto/sha256"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cloudflaretunneloperatorv1alpha1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1alpha1"
+	cloudflaretunneloperatorv1beta1 "github.com/beezlabs-org/cloudflare-tunnel-operator/api/v1beta1"
 	"github.com/beezlabs-org/cloudflare-tunnel-operator/controllers"
 	//+kubebuilder:scaffold:imports
 )
@@ -45,6 +51,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(cloudflaretunneloperatorv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(cloudflaretunneloperatorv1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -52,11 +59,26 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var watchNamespaces string
+	var shardSelector string
+	var clusterID string
+	var tunnelNameTemplate string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated list of the namespaces the manager watches. "+
+			"All namespaces are watched when it is empty.")
+	flag.StringVar(&shardSelector, "shard-selector", "",
+		"Label selector of the resources reconciled by this manager, to split them across several instances. "+
+			"All resources are reconciled when it is empty.")
+	flag.StringVar(&clusterID, "cluster-id", "",
+		"Identifies the cluster in the names of the tunnels when several clusters share a Cloudflare account.")
+	flag.StringVar(&tunnelNameTemplate, "tunnel-name-template", controllers.DefaultTunnelNameTemplate,
+		"Go template of the names of the tunnels in Cloudflare, with the fields .ClusterID, .Namespace and .Name. "+
+			"Existing tunnels keep the name they were given.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,26 +87,90 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	var selector labels.Selector
+	leaderElectionID := "a6b1ac6f.beezlabs.app"
+	if shardSelector != "" {
+		var err error
+		if selector, err = labels.Parse(shardSelector); err != nil {
+			setupLog.Error(err, "unable to parse shard selector")
+			os.Exit(1)
+		}
+		// each shard elects its own leader, the parsed selector is used so that equivalent selectors share the id
+		hash := sha256.Sum256([]byte(selector.String()))
+		leaderElectionID = fmt.Sprintf("a6b1ac6f-%x.beezlabs.app", hash[:4])
+		setupLog.Info("reconciling shard", "selector", selector.String(), "leaderElectionID", leaderElectionID)
+	}
+
+	tunnelNameTmpl, err := controllers.ParseTunnelNameTemplate(tunnelNameTemplate)
+	if err != nil {
+		setupLog.Error(err, "unable to parse tunnel name template")
+		os.Exit(1)
+	}
+
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "a6b1ac6f.beezlabs.app",
-	})
+		LeaderElectionID:       leaderElectionID,
+	}
+	// a single namespace is supported by the default cache, several need a cache per namespace
+	namespaces := splitNamespaces(watchNamespaces)
+	switch {
+	case len(namespaces) == 1:
+		options.Namespace = namespaces[0]
+	case len(namespaces) > 1:
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	if len(namespaces) != 0 {
+		setupLog.Info("watching namespaces", "namespaces", namespaces)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
 	if err = (&controllers.CloudflareTunnelReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:             mgr.GetClient(),
+		APIReader:          mgr.GetAPIReader(),
+		Scheme:             mgr.GetScheme(),
+		ShardSelector:      selector,
+		TunnelNameTemplate: tunnelNameTmpl,
+		ClusterID:          clusterID,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CloudflareTunnel")
 		os.Exit(1)
 	}
+	if err = (&controllers.CloudflareVirtualNetworkReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		ShardSelector: selector,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CloudflareVirtualNetwork")
+		os.Exit(1)
+	}
+	if err = (&controllers.AccessServiceTokenReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		ShardSelector: selector,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AccessServiceToken")
+		os.Exit(1)
+	}
+	// the webhooks need certificates, which are not available when running locally with `make run`
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&cloudflaretunneloperatorv1alpha1.CloudflareTunnel{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CloudflareTunnel")
+			os.Exit(1)
+		}
+		if err = (&cloudflaretunneloperatorv1beta1.CloudflareTunnel{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CloudflareTunnel")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -102,3 +188,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitNamespaces parses the comma separated list of namespaces, ignoring empty entries
+func splitNamespaces(list string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(list, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}